@@ -0,0 +1,116 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
+	"github.com/apache/skywalking-kubernetes-event-exporter/pkg/k8s"
+	"github.com/apache/skywalking-kubernetes-event-exporter/pkg/pipe"
+)
+
+var (
+	replayEvents   []string
+	replayFixtures []string
+	replayCluster  string
+	replayDryRun   bool
+)
+
+func init() {
+	replayCmd.Flags().StringSliceVarP(&replayEvents, "events", "f", nil,
+		"the files of the recorded events, in JSON, YAML, JSON Lines, or the output of `kubectl get events -o json`")
+	replayCmd.Flags().StringSliceVar(&replayFixtures, "fixtures", nil,
+		"the files of the Pods, Services, Endpoints and workloads that are used to render the templates, in the same formats as the events")
+	replayCmd.Flags().StringVar(&replayCluster, "cluster", "", "the cluster name of the replayed events")
+	replayCmd.Flags().BoolVar(&replayDryRun, "dry-run", false, "print the events with the console exporter rather than sending them to the configured exporters")
+	_ = replayCmd.MarkFlagRequired("events")
+
+	rootCmd.AddCommand(replayCmd)
+}
+
+var replayCmd = &cobra.Command{
+	Use:     "replay",
+	Short:   "Replay the recorded events through the configured filters, templates and exporters",
+	Example: "  skywalking-kubernetes-event-exporter replay -c config.yaml -f events.json --fixtures pods.yaml --dry-run",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		addShutDownHook(cancel, nil)
+
+		var objects []runtime.Object
+		for _, file := range append(replayEvents, replayFixtures...) {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			objs, err := k8s.DecodeObjects(content)
+			if err != nil {
+				return fmt.Errorf("failed to decode %v: %w", file, err)
+			}
+			objects = append(objects, objs...)
+		}
+
+		var events []*k8s.Event
+		var fixtures []runtime.Object
+		for _, obj := range objects {
+			if e, ok := obj.(*v1.Event); ok {
+				events = append(events, &k8s.Event{Event: e, Cluster: replayCluster})
+			} else {
+				fixtures = append(fixtures, obj)
+			}
+		}
+
+		logger.Log.Infof("replaying %v events with %v fixtures", len(events), len(fixtures))
+
+		if err := k8s.Registry.AddStatic(replayCluster, fixtures); err != nil {
+			return err
+		}
+
+		p := &pipe.Pipe{
+			DryRun: replayDryRun,
+		}
+		if err := p.Init(ctx); err != nil {
+			return err
+		}
+
+		ch := make(chan *k8s.Event)
+		go func() {
+			defer close(ch)
+
+			for _, e := range events {
+				select {
+				case ch <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		return p.Run(ctx, ch)
+	},
+}
@@ -0,0 +1,295 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	sw "skywalking.apache.org/repo/goapi/collect/event/v3"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/configs"
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
+	exp "github.com/apache/skywalking-kubernetes-event-exporter/pkg/exporter"
+	"github.com/apache/skywalking-kubernetes-event-exporter/pkg/k8s"
+)
+
+var (
+	replayDLQFiles    []string
+	replayDLQExporter string
+)
+
+func init() {
+	replayDLQCmd.Flags().StringSliceVarP(&replayDLQFiles, "files", "f", nil, "the dead letter files in JSON Lines")
+	replayDLQCmd.Flags().StringVar(&replayDLQExporter, "exporter", "", "the exporter to resend the events to, rather than the ones that failed to export them")
+	_ = replayDLQCmd.MarkFlagRequired("files")
+
+	rootCmd.AddCommand(replayDLQCmd)
+}
+
+var replayDLQCmd = &cobra.Command{
+	Use:     "replay-dlq",
+	Short:   "Resend the events in the dead letter files to the exporters",
+	Example: "  skywalking-kubernetes-event-exporter replay-dlq -c config.yaml -f dead-letters.jsonl",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		addShutDownHook(cancel, nil)
+
+		files := map[string][]*exp.DeadLetter{}
+		digests := map[*exp.DeadLetter]string{}
+		letters := map[string][]*exp.DeadLetter{}
+		for _, file := range replayDLQFiles {
+			ls, err := readUnreplayedDeadLetters(file, digests)
+			if err != nil {
+				return err
+			}
+			files[file] = ls
+			for _, letter := range ls {
+				name := letter.Exporter
+				if replayDLQExporter != "" {
+					name = replayDLQExporter
+				}
+				letters[name] = append(letters[name], letter)
+			}
+		}
+
+		names := make([]string, 0, len(letters))
+		for name := range letters {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		unresent := map[*exp.DeadLetter]bool{}
+		for _, name := range names {
+			failed, err := resendDeadLetters(ctx, name, letters[name])
+			if err != nil {
+				logger.Log.Errorf("failed to resend %v of %v events to %v. %+v", len(failed), len(letters[name]), name, err)
+			}
+			for _, letter := range failed {
+				unresent[letter] = true
+			}
+			if resent := len(letters[name]) - len(failed); resent > 0 {
+				logger.Log.Infof("resent %v events to %v", resent, name)
+			}
+		}
+
+		for _, file := range replayDLQFiles {
+			if err := markReplayed(file, files[file], digests, unresent); err != nil {
+				return fmt.Errorf("failed to mark the resent events of %v as replayed: %w", file, err)
+			}
+		}
+		if len(unresent) > 0 {
+			return fmt.Errorf("%v events are not resent, they are replayed again by the next replay", len(unresent))
+		}
+
+		return nil
+	},
+}
+
+// resendDeadLetters resends the rendered events of the dead letters if the exporter supports,
+// otherwise, the Kubernetes events are exported again. The dead letters that are not resent are returned.
+func resendDeadLetters(ctx context.Context, name string, letters []*exp.DeadLetter) ([]*exp.DeadLetter, error) {
+	config, ok := configs.GlobalConfig.Exporters[name]
+	if !ok {
+		return letters, fmt.Errorf("exporter %v is not defined in the exporters section", name)
+	}
+	exporter := exp.GetExporter(name)
+	if exporter == nil {
+		return letters, fmt.Errorf("exporter %v is not defined", name)
+	}
+	if err := exporter.Init(ctx, config); err != nil {
+		return letters, err
+	}
+	// The events are redacted like they are by the pipe, in case they were dead-lettered before the redaction was enabled.
+	redaction := configs.GlobalConfig.Redaction
+	if err := redaction.Init(); err != nil {
+		return letters, err
+	}
+	if redactingExporter, ok := exporter.(exp.RedactingExporter); ok {
+		redactingExporter.SetRedaction(&redaction)
+	}
+
+	if resender, ok := exporter.(exp.Resender); ok {
+		var rendered []*sw.Event
+		for _, letter := range letters {
+			if letter.Rendered != nil {
+				rendered = append(rendered, letter.Rendered)
+			}
+		}
+		if len(rendered) == len(letters) {
+			if resent, err := resender.Resend(ctx, rendered); err != nil {
+				return letters[resent:], err
+			}
+			return nil, nil
+		}
+	}
+
+	// The objects of the events may not exist anymore, so the templates are rendered without them.
+	clusters := map[string]bool{}
+	for _, letter := range letters {
+		if !clusters[letter.Cluster] {
+			clusters[letter.Cluster] = true
+			if err := k8s.Registry.AddStatic(letter.Cluster, nil); err != nil {
+				return letters, err
+			}
+		}
+	}
+
+	// The events that fail to be exported again are sent to the dead letters of the exporter,
+	// the failures of the exporters that don't support the dead letters cannot be told.
+	failed := &failedEvents{events: map[*corev1.Event]bool{}}
+	if deadLetterExporter, ok := exporter.(exp.DeadLetterExporter); ok {
+		deadLetterExporter.SetDeadLetters(failed)
+	}
+
+	events := make(chan *k8s.Event)
+	go func() {
+		defer close(events)
+
+		for _, letter := range letters {
+			// The events of the dead letters are not shared, so they are redacted in place.
+			letter.Event.Message = redaction.Redact(letter.Event.Message)
+			select {
+			case events <- &k8s.Event{Event: letter.Event, Cluster: letter.Cluster}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	exporter.Export(ctx, events)
+
+	// The events in flight when the command is interrupted may or may not be exported.
+	if err := ctx.Err(); err != nil {
+		return letters, err
+	}
+	var unresent []*exp.DeadLetter
+	for _, letter := range letters {
+		if failed.events[letter.Event] {
+			unresent = append(unresent, letter)
+		}
+	}
+	if len(unresent) > 0 {
+		return unresent, fmt.Errorf("%v events failed to be exported", len(unresent))
+	}
+	return nil, nil
+}
+
+// failedEvents records the events that fail to be exported again.
+type failedEvents struct {
+	sync.Mutex
+	events map[*corev1.Event]bool
+}
+
+func (f *failedEvents) Send(letter *exp.DeadLetter) {
+	f.Lock()
+	defer f.Unlock()
+	f.events[letter.Event] = true
+}
+
+// replayedFile is the file of the digests of the resent dead letters of the dead letter file, the dead
+// letter file itself is never rewritten, because it may still be appended to by the running exporters.
+func replayedFile(file string) string {
+	return file + ".replayed"
+}
+
+// digestOf returns the digest that identifies the dead letter in the replayed file.
+func digestOf(letter *exp.DeadLetter) (string, error) {
+	bytes, err := json.Marshal(letter)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(bytes)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// readUnreplayedDeadLetters reads the dead letters of the file that are not resent by the previous replays,
+// and records their digests.
+func readUnreplayedDeadLetters(file string, digests map[*exp.DeadLetter]string) ([]*exp.DeadLetter, error) {
+	replayed := map[string]bool{}
+	if bytes, err := os.ReadFile(replayedFile(file)); err == nil {
+		for _, digest := range strings.Fields(string(bytes)) {
+			replayed[digest] = true
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	letters, err := exp.ReadDeadLetters(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %v: %w", file, err)
+	}
+
+	var unreplayed []*exp.DeadLetter
+	for _, letter := range letters {
+		digest, err := digestOf(letter)
+		if err != nil {
+			return nil, err
+		}
+		if replayed[digest] {
+			continue
+		}
+		digests[letter] = digest
+		unreplayed = append(unreplayed, letter)
+	}
+	if skipped := len(letters) - len(unreplayed); skipped > 0 {
+		logger.Log.Infof("skipped %v events of %v that are already resent", skipped, file)
+	}
+	return unreplayed, nil
+}
+
+// markReplayed appends the digests of the resent dead letters of the file to its replayed file,
+// so that they are not resent again by the next replay.
+func markReplayed(file string, letters []*exp.DeadLetter, digests map[*exp.DeadLetter]string, unresent map[*exp.DeadLetter]bool) error {
+	var buf strings.Builder
+	for _, letter := range letters {
+		if !unresent[letter] {
+			buf.WriteString(digests[letter] + "\n")
+		}
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(replayedFile(file), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(buf.String()); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
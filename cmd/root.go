@@ -41,19 +41,13 @@ var rootCmd = &cobra.Command{
 	SilenceUsage:  true,
 	SilenceErrors: true,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		level, err := logrus.ParseLevel(verbosity)
-		if err != nil {
+		if err := setLogLevel(); err != nil {
 			return err
 		}
-		logger.Log.SetLevel(level)
 
-		content := assets.DefaultConfig
-		if configFile != "" {
-			c, err := os.ReadFile(configFile)
-			if err != nil {
-				return err
-			}
-			content = c
+		content, err := readConfig()
+		if err != nil {
+			return err
 		}
 		if err := configs.ParseConfig(content); err != nil {
 			return err
@@ -63,6 +57,23 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+func setLogLevel() error {
+	level, err := logrus.ParseLevel(verbosity)
+	if err != nil {
+		return err
+	}
+	logger.Log.SetLevel(level)
+	return nil
+}
+
+// readConfig reads the content of the config file, or the default config if no file is specified.
+func readConfig() ([]byte, error) {
+	if configFile == "" {
+		return assets.DefaultConfig, nil
+	}
+	return os.ReadFile(configFile)
+}
+
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&verbosity, "verbosity", "v", logrus.InfoLevel.String(), "log level (debug, info, warn, error, fatal, panic")
 	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "the config file")
@@ -21,19 +21,43 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
-	v1 "k8s.io/api/core/v1"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"k8s.io/client-go/rest"
 
+	"github.com/apache/skywalking-kubernetes-event-exporter/configs"
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/filewatch"
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/metrics"
 	"github.com/apache/skywalking-kubernetes-event-exporter/pkg/k8s"
 	"github.com/apache/skywalking-kubernetes-event-exporter/pkg/pipe"
 )
 
+var (
+	configReloadInterval time.Duration
+	metricsAddress       string
+	shutdownGracePeriod  time.Duration
+)
+
 func init() {
+	startCmd.Flags().DurationVar(&configReloadInterval, "config-reload-interval", 10*time.Second,
+		"the interval to check the config file for changes and reload it, 0 disables the reloading")
+	startCmd.Flags().DurationVar(&shutdownGracePeriod, "shutdown-grace-period", 25*time.Second,
+		"the period to export the in-flight events after receiving SIGTERM or SIGINT, the events not exported by then are dropped")
+	startCmd.Flags().StringVar(&metricsAddress, "metrics-address", "", "the address to expose the metrics at /debug/vars, empty disables the metrics")
+	startCmd.Flags().StringVar(&k8s.Options.Kubeconfig, "kubeconfig", "", "path to the kubeconfig file, defaults to $KUBECONFIG, $HOME/.kube/config or in-cluster config")
+	startCmd.Flags().StringVar(&k8s.Options.Context, "context", "", "the kubeconfig context to use, defaults to the current context")
+	startCmd.Flags().StringVar(&k8s.Options.Master, "master", "", "the address of the Kubernetes API server, overrides the one in kubeconfig")
+	startCmd.Flags().Float32Var(&k8s.Options.QPS, "kube-api-qps", 0, "the maximum queries per second to the Kubernetes API server, 0 means client-go's default")
+	startCmd.Flags().IntVar(&k8s.Options.Burst, "kube-api-burst", 0, "the maximum burst for throttle of the Kubernetes API server, 0 means client-go's default")
+	startCmd.Flags().StringVar(&k8s.Options.UserAgent, "user-agent", "", "the user agent sent to the Kubernetes API server, defaults to client-go's default")
+
 	rootCmd.AddCommand(startCmd)
 }
 
@@ -42,31 +66,125 @@ var startCmd = &cobra.Command{
 	Short: "Start skywalking-kubernetes-event-exporter",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
 
-		addShutDownHook(cancel)
-
-		watcher, err := k8s.WatchEvents(ctx, v1.NamespaceAll)
+		// The registries are started with the options of the initial config, which cannot be reloaded.
+		registryOptions := configs.GlobalConfig.RegistryOptions()
+		clusters, err := newClusters(ctx, registryOptions)
 		if err != nil {
 			return err
 		}
 
-		p := pipe.Pipe{
-			Watcher: watcher,
+		p := &pipe.Pipe{
+			Clusters: clusters,
 		}
 
 		if err = p.Init(ctx); err != nil {
 			return err
 		}
 
+		// The config file is not watched any more once shutting down, while the metrics are served
+		// until the in-flight events are exported.
+		// A second signal stops the exporters at once rather than waiting for the grace period.
+		watchCtx, stopWatching := context.WithCancel(ctx)
+		addShutDownHook(func() {
+			stopWatching()
+			p.Shutdown(shutdownGracePeriod)
+		}, p.Abort)
+
+		if metricsAddress != "" {
+			go metrics.Serve(ctx, metricsAddress)
+		}
+
+		if configFile != "" && configReloadInterval > 0 {
+			go filewatch.Watch(watchCtx, configFile, configReloadInterval, func(content []byte) {
+				err := reloadConfig(p, registryOptions, content)
+				metrics.ConfigReloaded(err)
+				if err != nil {
+					logger.Log.Errorf("failed to reload config, keep running with the previous one. %+v", err)
+				} else {
+					logger.Log.Infof("config has been reloaded")
+				}
+			})
+		}
+
 		return p.Start(ctx)
 	},
 }
 
-func addShutDownHook(stopFunc func()) {
-	c := make(chan os.Signal, 1)
+// reloadConfig parses the content and applies the new filters and exporters to the pipe, the clusters and
+// the registry cannot be reloaded because the informers are not restarted, so the applied ones are kept.
+func reloadConfig(p *pipe.Pipe, registryOptions k8s.RegistryOptions, content []byte) error {
+	config, err := configs.Parse(content)
+	if err != nil {
+		return err
+	}
+
+	if err := p.Reload(config); err != nil {
+		return err
+	}
+
+	next, unapplied := configs.GlobalConfig.Reload(config, registryOptions)
+	for _, section := range unapplied {
+		logger.Log.Warnf("%v has been changed, restart to apply the changes", section)
+	}
+	configs.GlobalConfig = *next
+
+	return nil
+}
+
+// newClusters creates the clusters defined in the configurations, or the only one
+// cluster connected with the command line options if there is no cluster defined.
+func newClusters(ctx context.Context, registryOptions k8s.RegistryOptions) ([]*k8s.Cluster, error) {
+	clusterConfigs := configs.GlobalConfig.Clusters
+	implicit := len(clusterConfigs) == 0
+	if implicit {
+		clusterConfigs = []*configs.ClusterConfig{{}}
+	}
+
+	var clusters []*k8s.Cluster
+	names := map[string]bool{}
+	for _, c := range clusterConfigs {
+		if names[c.Name] {
+			return nil, fmt.Errorf("cluster %+v is defined more than once", c.Name)
+		}
+		names[c.Name] = true
+
+		var config *rest.Config
+		var err error
+		if implicit {
+			config, err = k8s.GetConfig()
+		} else {
+			config, err = c.RESTConfig(ctx)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config of cluster %+v: %w", c.Name, err)
+		}
+
+		cluster, err := k8s.NewCluster(c.Name, config, c.InformerOptions(), registryOptions)
+		if err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, cluster)
+	}
+
+	return clusters, nil
+}
+
+// addShutDownHook calls stopFunc on the first interrupt or termination signal, and forceFunc on the second one,
+// so that a slow graceful stop can be forced, the process exits at once on the second signal if forceFunc is nil.
+func addShutDownHook(stopFunc, forceFunc func()) {
+	c := make(chan os.Signal, 2)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
 		stopFunc()
+
+		<-c
+		logger.Log.Warnf("received the signal again, stopping at once")
+		if forceFunc == nil {
+			os.Exit(1)
+		}
+		forceFunc()
 	}()
 }
@@ -0,0 +1,72 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/configs"
+	exp "github.com/apache/skywalking-kubernetes-event-exporter/pkg/exporter"
+)
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
+
+var validateCmd = &cobra.Command{
+	Use:     "validate",
+	Short:   "Validate the config file without connecting to Kubernetes or the exporters' backends",
+	Example: "  skywalking-kubernetes-event-exporter validate -c config.yaml",
+	// Overrides the root command's hook, so that the problems are reported by this command rather than
+	// failing on the first one when parsing.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return setLogLevel()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		content, err := readConfig()
+		if err != nil {
+			return err
+		}
+
+		file := configFile
+		if file == "" {
+			file = "<default config>"
+		}
+
+		problems := configs.Validate(content, exp.Validate)
+		for _, problem := range problems {
+			if problem.Path == "" {
+				fmt.Printf("%v:%v: %v\n", file, problem.Line, problem.Err)
+			} else {
+				fmt.Printf("%v:%v: %v: %v\n", file, problem.Line, problem.Path, problem.Err)
+			}
+		}
+
+		if len(problems) > 0 {
+			return fmt.Errorf("%v problem(s) found in %v", len(problems), file)
+		}
+
+		fmt.Printf("%v is valid\n", file)
+
+		return nil
+	},
+}
@@ -21,13 +21,16 @@ package configs
 
 import (
 	"context"
+	"fmt"
+	"reflect"
 
 	"gopkg.in/yaml.v3"
 
-	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
 
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
 	"github.com/apache/skywalking-kubernetes-event-exporter/pkg/k8s"
@@ -51,26 +54,54 @@ type FilterConfig struct {
 	nameRegExp      *regexp.Regexp
 	Service         string `yaml:"service"`
 	serviceRegExp   *regexp.Regexp
+	Cluster         string `yaml:"cluster"`
+	clusterRegExp   *regexp.Regexp
+	Workload        string `yaml:"workload"`
+	workloadRegExp  *regexp.Regexp
+	// Object filters the fields of the involved object, keyed by the dot-separated
+	// field path like `spec.storageClassName`, the values are regular expressions.
+	Object        map[string]string `yaml:"object"`
+	objectRegExps map[string]*regexp.Regexp
 
 	Exporters []string `yaml:"exporters"`
 }
 
-func (filter *FilterConfig) Init() {
+func (filter *FilterConfig) Init() (err error) {
 	logger.Log.Debugf("initializing filter config")
 
-	filter.reasonRegExp = regexp.MustCompile(filter.Reason)
-	filter.messageRegExp = regexp.MustCompile(filter.Message)
-	filter.typeRegExp = regexp.MustCompile(filter.Type)
-	filter.actionRegExp = regexp.MustCompile(filter.Action)
-	filter.kindRegExp = regexp.MustCompile(filter.Kind)
-	filter.namespaceRegExp = regexp.MustCompile(filter.Namespace)
-	filter.nameRegExp = regexp.MustCompile(filter.Name)
-	filter.serviceRegExp = regexp.MustCompile(filter.Service)
+	compile := func(field, expr string, regExp **regexp.Regexp) {
+		if err != nil {
+			return
+		}
+		if *regExp, err = regexp.Compile(expr); err != nil {
+			err = fmt.Errorf("invalid regular expression of %v: %w", field, err)
+		}
+	}
+
+	compile("reason", filter.Reason, &filter.reasonRegExp)
+	compile("message", filter.Message, &filter.messageRegExp)
+	compile("type", filter.Type, &filter.typeRegExp)
+	compile("action", filter.Action, &filter.actionRegExp)
+	compile("kind", filter.Kind, &filter.kindRegExp)
+	compile("namespace", filter.Namespace, &filter.namespaceRegExp)
+	compile("name", filter.Name, &filter.nameRegExp)
+	compile("service", filter.Service, &filter.serviceRegExp)
+	compile("cluster", filter.Cluster, &filter.clusterRegExp)
+	compile("workload", filter.Workload, &filter.workloadRegExp)
+
+	filter.objectRegExps = map[string]*regexp.Regexp{}
+	for path, expr := range filter.Object {
+		var regExp *regexp.Regexp
+		compile("object."+path, expr, &regExp)
+		filter.objectRegExps[path] = regExp
+	}
+
+	return err
 }
 
 // Filter the given event with this filter instance.
 // Return true if the event is filtered, return false otherwise.
-func (filter *FilterConfig) Filter(ctx context.Context, event *v1.Event) bool {
+func (filter *FilterConfig) Filter(ctx context.Context, event *k8s.Event) bool {
 	if filter.Reason != "" && !filter.reasonRegExp.MatchString(event.Reason) {
 		return true
 	}
@@ -95,9 +126,33 @@ func (filter *FilterConfig) Filter(ctx context.Context, event *v1.Event) bool {
 	if filter.Name != "" && !filter.nameRegExp.MatchString(event.InvolvedObject.Name) {
 		return true
 	}
-	if filter.Service != "" {
+	if filter.Cluster != "" && !filter.clusterRegExp.MatchString(event.Cluster) {
+		return true
+	}
+	if filter.Service != "" || filter.Workload != "" || len(filter.Object) > 0 {
 		c := <-k8s.Registry.GetContext(ctx, event)
-		if svcName := strings.TrimSpace(c.Service.Name); !filter.serviceRegExp.MatchString(svcName) {
+		if filter.Service != "" && !filter.matchService(c) {
+			return true
+		}
+		if filter.Workload != "" && !filter.workloadRegExp.MatchString(c.Workload.Name) {
+			return true
+		}
+		for path, regExp := range filter.objectRegExps {
+			if !regExp.MatchString(k8s.FieldOf(c.Object, path)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchService returns true if any of the Services that the event belongs to matches the filter.
+func (filter *FilterConfig) matchService(c k8s.TemplateContext) bool {
+	if len(c.Services) == 0 {
+		return filter.serviceRegExp.MatchString(strings.TrimSpace(c.Service.Name))
+	}
+	for _, svc := range c.Services {
+		if filter.serviceRegExp.MatchString(strings.TrimSpace(svc.Name)) {
 			return true
 		}
 	}
@@ -106,13 +161,138 @@ func (filter *FilterConfig) Filter(ctx context.Context, event *v1.Event) bool {
 
 type ExporterConfig map[string]interface{}
 
+// ClusterConfig configures a cluster that the exporter watches events from.
+type ClusterConfig struct {
+	Name       string        `yaml:"name"`
+	Kubeconfig string        `yaml:"kubeconfig"`
+	Context    string        `yaml:"context"`
+	Secret     *SecretConfig `yaml:"secret"`
+	// Namespace limits the events and the namespaced objects to watch, empty means all the namespaces.
+	Namespace string `yaml:"namespace"`
+	// ResyncPeriod is the resync period of the informers, like "10m", 0 disables resyncing.
+	ResyncPeriod time.Duration `yaml:"resyncPeriod"`
+}
+
+// SecretConfig refers to a Secret that contains a kubeconfig file.
+type SecretConfig struct {
+	Namespace string `yaml:"namespace"`
+	Name      string `yaml:"name"`
+	Key       string `yaml:"key"`
+}
+
+// InformerOptions returns the options of the shared informers of the cluster.
+func (cluster *ClusterConfig) InformerOptions() k8s.InformerOptions {
+	return k8s.InformerOptions{
+		Namespace: cluster.Namespace,
+		Resync:    cluster.ResyncPeriod,
+	}
+}
+
+// RESTConfig returns the configuration to connect to the Kubernetes API server of the configured cluster,
+// the kubeconfig Secret takes precedence over the kubeconfig file, which defaults to the one of the command
+// line. Only the client options of the command line, like the QPS, apply to the configured clusters, while
+// the context and the master are meant for the default cluster, or all the clusters would be the same one.
+func (cluster *ClusterConfig) RESTConfig(ctx context.Context) (*rest.Config, error) {
+	options := k8s.ClientOptions{
+		Kubeconfig: k8s.Options.Kubeconfig,
+		Context:    cluster.Context,
+		QPS:        k8s.Options.QPS,
+		Burst:      k8s.Options.Burst,
+		UserAgent:  k8s.Options.UserAgent,
+	}
+	if cluster.Kubeconfig != "" {
+		options.Kubeconfig = cluster.Kubeconfig
+	}
+
+	if secret := cluster.Secret; secret != nil {
+		key := secret.Key
+		if key == "" {
+			key = "kubeconfig"
+		}
+		return options.ConfigFromSecret(ctx, secret.Namespace, secret.Name, key)
+	}
+
+	return options.Config()
+}
+
+// RegistryConfig configures the registry that enriches the events with the involved objects.
+type RegistryConfig struct {
+	// Kinds are the kinds of the involved objects to watch, in the form of `Kind.group`
+	// like `Ingress.networking.k8s.io`, the objects of other kinds are fetched on demand.
+	Kinds []string `yaml:"kinds"`
+	// CacheSize is the maximum number of the objects fetched on demand, and the recently deleted objects, to cache.
+	CacheSize int `yaml:"cacheSize"`
+	// DeletedObjectTTL is how long the deleted objects are kept to enrich the events, like "30s".
+	DeletedObjectTTL time.Duration `yaml:"deletedObjectTTL"`
+	// KeepFields are the fields to keep in the informers in addition to the ones referenced by the
+	// templates and the filters, like "Pod.Spec", the other fields are dropped to reduce the memory.
+	KeepFields []string `yaml:"keepFields"`
+}
+
+// Options returns the options of the registries of the clusters configured in the registry section,
+// see Config.RegistryOptions for the complete options.
+func (registry *RegistryConfig) Options() k8s.RegistryOptions {
+	return k8s.RegistryOptions{
+		Kinds:            registry.Kinds,
+		CacheSize:        registry.CacheSize,
+		DeletedObjectTTL: registry.DeletedObjectTTL,
+		KeepFields:       registry.KeepFields,
+	}
+}
+
+// DeadLetterConfig configures where the events that fail to be exported are sent to,
+// only one of File and Exporter can be specified.
+type DeadLetterConfig struct {
+	// File is the file that the events are appended to in JSON Lines, with the reasons of the failures.
+	File string `yaml:"file"`
+	// Exporter is the name of another exporter in the exporters section that the events are exported to.
+	Exporter string `yaml:"exporter"`
+}
+
 type Config struct {
-	Filters   []*FilterConfig           `mapstructure:"filters"`
-	Exporters map[string]ExporterConfig `mapstructure:"exporters"`
+	Clusters   []*ClusterConfig          `yaml:"clusters"`
+	Registry   RegistryConfig            `yaml:"registry"`
+	DeadLetter DeadLetterConfig          `yaml:"deadLetter"`
+	Redaction  RedactionConfig           `yaml:"redaction"`
+	Filters    []*FilterConfig           `yaml:"filters"`
+	Exporters  map[string]ExporterConfig `yaml:"exporters"`
 }
 
 var GlobalConfig Config
 
 func ParseConfig(content []byte) error {
-	return yaml.Unmarshal(content, &GlobalConfig)
+	config, err := Parse(content)
+	if err != nil {
+		return err
+	}
+	GlobalConfig = *config
+	return nil
+}
+
+// Parse parses the content into a new Config, without changing the GlobalConfig.
+func Parse(content []byte) (*Config, error) {
+	config := &Config{}
+	if err := yaml.Unmarshal(content, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Reload returns the config to run with after the config is reloaded to the given one. The clusters and the
+// registry take effect only when the informers are started, so the applied ones are kept, and the names of the
+// sections that are changed but not applied are returned. The registryOptions are the ones the registries are
+// started with, which also depend on the fields referenced by the templates and the filters.
+func (config *Config) Reload(reloaded *Config, registryOptions k8s.RegistryOptions) (*Config, []string) {
+	var unapplied []string
+	if !reflect.DeepEqual(reloaded.Clusters, config.Clusters) {
+		unapplied = append(unapplied, "clusters")
+	}
+	if !reflect.DeepEqual(reloaded.RegistryOptions(), registryOptions) {
+		unapplied = append(unapplied, "registry")
+	}
+
+	next := *reloaded
+	next.Clusters = config.Clusters
+	next.Registry = config.Registry
+	return &next, unapplied
 }
@@ -21,12 +21,33 @@ package configs
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/pkg/k8s"
 )
 
 func TestFilterConfig_Filter(t *testing.T) {
+	storageClassName := "gp2"
+	if err := k8s.Registry.AddStatic("fixtures", []runtime.Object{
+		&v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "data"},
+			Spec:       v1.PersistentVolumeClaimSpec{StorageClassName: &storageClassName},
+		},
+	}); err != nil {
+		t.Fatalf("AddStatic() error = %v", err)
+	}
+	pvcEvent := &v1.Event{
+		InvolvedObject: v1.ObjectReference{APIVersion: "v1", Kind: "PersistentVolumeClaim", Namespace: "default", Name: "data"},
+	}
+
 	type fields struct {
 		Reason    string
 		Message   string
@@ -36,10 +57,13 @@ func TestFilterConfig_Filter(t *testing.T) {
 		Kind      string
 		Namespace string
 		Name      string
+		Cluster   string
+		Object    map[string]string
 		Exporters []string
 	}
 	type args struct {
-		event *v1.Event
+		event   *v1.Event
+		cluster string
 	}
 	tests := []struct {
 		name   string
@@ -84,6 +108,38 @@ func TestFilterConfig_Filter(t *testing.T) {
 			args:   args{event: &v1.Event{Message: "Started reviews"}},
 			want:   true,
 		},
+
+		{
+			name:   "filter cluster by regexp",
+			fields: fields{Cluster: "prod-.*"},
+			args:   args{event: &v1.Event{}, cluster: "prod-east"},
+			want:   false,
+		},
+		{
+			name:   "filter cluster by regexp",
+			fields: fields{Cluster: "prod-.*"},
+			args:   args{event: &v1.Event{}, cluster: "staging"},
+			want:   true,
+		},
+
+		{
+			name:   "filter object field by regexp",
+			fields: fields{Object: map[string]string{"spec.storageClassName": "^gp"}},
+			args:   args{event: pvcEvent, cluster: "fixtures"},
+			want:   false,
+		},
+		{
+			name:   "filter object field by regexp",
+			fields: fields{Object: map[string]string{"spec.storageClassName": "^standard$"}},
+			args:   args{event: pvcEvent, cluster: "fixtures"},
+			want:   true,
+		},
+		{
+			name:   "filter absent object field",
+			fields: fields{Object: map[string]string{"spec.volumeName": "^$"}},
+			args:   args{event: pvcEvent, cluster: "fixtures"},
+			want:   false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -96,12 +152,203 @@ func TestFilterConfig_Filter(t *testing.T) {
 				Kind:      tt.fields.Kind,
 				Namespace: tt.fields.Namespace,
 				Name:      tt.fields.Name,
+				Cluster:   tt.fields.Cluster,
+				Object:    tt.fields.Object,
 				Exporters: tt.fields.Exporters,
 			}
-			filter.Init()
-			if got := filter.Filter(context.Background(), tt.args.event); got != tt.want {
+			if err := filter.Init(); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+			if got := filter.Filter(context.Background(), &k8s.Event{Event: tt.args.event, Cluster: tt.args.cluster}); got != tt.want {
 				t.Errorf("Filter() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestClusterConfig_InformerOptions(t *testing.T) {
+	config, err := Parse([]byte(`
+clusters:
+  - name: prod
+    namespace: bookinfo
+    resyncPeriod: 10m
+  - name: staging
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if got, want := config.Clusters[0].InformerOptions(), (k8s.InformerOptions{Namespace: "bookinfo", Resync: 10 * time.Minute}); got != want {
+		t.Errorf("InformerOptions() = %+v, want %+v", got, want)
+	}
+	if got := config.Clusters[1].InformerOptions(); got != (k8s.InformerOptions{}) {
+		t.Errorf("InformerOptions() = %+v, want all the namespaces without resyncing", got)
+	}
+}
+
+const testKubeconfig = `
+apiVersion: v1
+kind: Config
+current-context: staging
+clusters:
+- name: staging
+  cluster:
+    server: https://staging.example.com
+- name: production
+  cluster:
+    server: https://production.example.com
+contexts:
+- name: staging
+  context:
+    cluster: staging
+    user: admin
+- name: production
+  context:
+    cluster: production
+    user: admin
+users:
+- name: admin
+  user:
+    token: token
+`
+
+func TestClusterConfig_RESTConfig(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(kubeconfig, []byte(testKubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The context and the master of the command line are meant for the default cluster only.
+	options := *k8s.Options
+	k8s.Options = &k8s.ClientOptions{Kubeconfig: kubeconfig, Context: "staging", Master: "https://override.example.com", QPS: 50}
+	defer func() {
+		k8s.Options = &options
+	}()
+
+	tests := []struct {
+		name     string
+		cluster  *ClusterConfig
+		wantHost string
+	}{
+		{name: "current context", cluster: &ClusterConfig{Name: "staging"}, wantHost: "https://staging.example.com"},
+		{name: "configured context", cluster: &ClusterConfig{Name: "production", Context: "production"}, wantHost: "https://production.example.com"},
+		{name: "configured kubeconfig", cluster: &ClusterConfig{Name: "production", Kubeconfig: kubeconfig, Context: "production"}, wantHost: "https://production.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := tt.cluster.RESTConfig(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Host != tt.wantHost || config.QPS != 50 {
+				t.Errorf("RESTConfig() host = %v, QPS = %v, want %v and the QPS of the command line", config.Host, config.QPS, tt.wantHost)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	config, err := Parse([]byte(`
+clusters:
+  - name: prod
+registry:
+  cacheSize: 10
+deadLetter:
+  file: dead-letters.jsonl
+redaction:
+  presets: [bearerToken]
+filters:
+  - exporters: [console]
+exporters:
+  console: {}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Clusters) != 1 || config.Registry.CacheSize != 10 || config.DeadLetter.File != "dead-letters.jsonl" ||
+		len(config.Redaction.Presets) != 1 || len(config.Filters) != 1 || len(config.Exporters) != 1 {
+		t.Errorf("Parse() = %+v, want every section decoded", config)
+	}
+}
+
+func TestConfig_Reload(t *testing.T) {
+	applied, err := Parse([]byte(`
+clusters:
+  - name: prod
+registry:
+  cacheSize: 10
+exporters:
+  console:
+    template:
+      message: "{{ .Pod.Name }}"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	registryOptions := applied.RegistryOptions()
+
+	tests := []struct {
+		name          string
+		content       string
+		wantUnapplied []string
+	}{
+		{
+			name: "reloadable sections only",
+			content: `
+clusters:
+  - name: prod
+registry:
+  cacheSize: 10
+exporters:
+  console:
+    template:
+      message: "{{ .Event.Message }}"
+`,
+		},
+		{
+			name: "clusters and registry",
+			content: `
+clusters:
+  - name: staging
+registry:
+  cacheSize: 20
+exporters:
+  console: {}
+`,
+			wantUnapplied: []string{"clusters", "registry"},
+		},
+		{
+			name: "fields referenced by the templates",
+			content: `
+clusters:
+  - name: prod
+registry:
+  cacheSize: 10
+exporters:
+  console:
+    template:
+      message: "{{ .Pod.Spec.NodeName }}"
+`,
+			wantUnapplied: []string{"registry"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reloaded, err := Parse([]byte(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			next, unapplied := applied.Reload(reloaded, registryOptions)
+			if !reflect.DeepEqual(unapplied, tt.wantUnapplied) {
+				t.Errorf("Reload() unapplied = %v, want %v", unapplied, tt.wantUnapplied)
+			}
+			if !reflect.DeepEqual(next.Clusters, applied.Clusters) || !reflect.DeepEqual(next.Registry, applied.Registry) {
+				t.Errorf("Reload() = %+v, want the applied clusters and registry kept", next)
+			}
+			if !reflect.DeepEqual(next.Exporters, reloaded.Exporters) {
+				t.Errorf("Reload() exporters = %v, want %v", next.Exporters, reloaded.Exporters)
+			}
+		})
+	}
+}
@@ -0,0 +1,353 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package configs
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"text/template/parse"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/pkg/k8s"
+)
+
+// keepableFields are the fields that can be kept in the informers, keyed by the kind.
+var keepableFields = map[string][]string{
+	"Pod":     {k8s.PodSpec, k8s.PodStatus},
+	"Service": {k8s.ServiceSpec, k8s.ServiceStatus},
+	"Node":    {k8s.NodeSpec, k8s.NodeStatus},
+}
+
+// metadataFields are the fields of the metadata that are always kept in the informers.
+var metadataFields = map[string]bool{
+	"Name": true, "Namespace": true, "Labels": true, "Annotations": true, "UID": true, "GenerateName": true,
+	"OwnerReferences": true, "CreationTimestamp": true, "DeletionTimestamp": true, "ObjectMeta": true,
+	"Kind": true, "APIVersion": true, "TypeMeta": true, "GetName": true, "GetNamespace": true, "GetLabels": true,
+}
+
+// objectFields are the fields of the template context that are the objects whose fields can be kept.
+var objectFields = map[string]bool{"Pod": true, "Service": true, "Services": true, "Node": true, "Object": true}
+
+// metadataFuncs are the functions that only reference the metadata of the objects,
+// like `{{ label "app" .Pod }}` and `{{ .Pod | annotation "owner" }}`.
+var metadataFuncs = map[string]bool{"label": true, "annotation": true}
+
+var undefinedFuncRegExp = regexp.MustCompile(`function "([^"]+)" not defined`)
+
+// value is what an expression of the templates evaluates to, as far as the referenced fields are concerned,
+// it's the template context itself, the object of a kind (or the Services), or something else.
+type value string
+
+const (
+	contextValue value = "."
+	otherValue   value = ""
+)
+
+// fieldWalker walks the parse trees of the templates and records the fields of the objects that are referenced.
+type fieldWalker struct {
+	trees  map[string]*parse.Tree
+	fields []string
+	// walking are the defined templates being walked with the given dot, to stop the recursive templates.
+	walking map[string]bool
+}
+
+// referencedFields returns the fields of the objects that are referenced by the templates in the content, the
+// dot and the variables bound to the objects by `with`, `range` and the variable declarations are followed, and
+// the fields of the objects that are referenced as a whole, like `{{ toJson .Pod }}`, are all referenced.
+func referencedFields(content string) []string {
+	trees, err := parseTemplate(content)
+	if err != nil {
+		// The invalid templates are rejected by the exporters, all the fields are kept in case they are not.
+		var fields []string
+		for _, f := range keepableFields {
+			fields = append(fields, f...)
+		}
+		return fields
+	}
+
+	w := &fieldWalker{trees: trees, walking: map[string]bool{}}
+	if tree := trees["fields"]; tree != nil && tree.Root != nil {
+		w.walk(tree.Root, contextValue, map[string]value{"$": contextValue})
+	}
+	return w.fields
+}
+
+// parseTemplate parses the content into the parse trees, the functions are not known here,
+// so the ones that are called are taken as defined, and it's up to the exporters to check them.
+func parseTemplate(content string) (map[string]*parse.Tree, error) {
+	funcs := map[string]interface{}{}
+	for {
+		trees, err := parse.Parse("fields", content, "", "", funcs, builtinFuncs)
+		if err == nil {
+			return trees, nil
+		}
+		match := undefinedFuncRegExp.FindStringSubmatch(err.Error())
+		if match == nil || funcs[match[1]] != nil {
+			return nil, err
+		}
+		funcs[match[1]] = struct{}{}
+	}
+}
+
+// builtinFuncs are the names of the predefined functions of text/template.
+var builtinFuncs = map[string]interface{}{
+	"and": true, "call": true, "html": true, "index": true, "slice": true, "js": true, "len": true, "not": true,
+	"or": true, "print": true, "printf": true, "println": true, "urlquery": true,
+	"eq": true, "ge": true, "gt": true, "le": true, "lt": true, "ne": true,
+}
+
+// reference records the fields of the object that are referenced, the fields are all referenced if field is empty.
+func (w *fieldWalker) reference(object value, field string) {
+	if metadataFields[field] {
+		return
+	}
+	switch object {
+	case contextValue, otherValue:
+		return
+	case "Object":
+		// The involved Pods, Services and Nodes are also available as .Object.
+		for _, f := range keepableFields {
+			w.fields = append(w.fields, f...)
+		}
+		return
+	case "Services":
+		object = "Service"
+	}
+	if field == "Spec" || field == "Status" {
+		w.fields = append(w.fields, string(object)+"."+field)
+	} else {
+		w.fields = append(w.fields, keepableFields[string(object)]...)
+	}
+}
+
+// use records that the value is used as a whole, like printed or passed to a function.
+func (w *fieldWalker) use(v value) {
+	w.reference(v, "")
+}
+
+// walk walks the node with the dot and the variables in scope.
+func (w *fieldWalker) walk(node parse.Node, dot value, vars map[string]value) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		for _, n := range node.Nodes {
+			w.walk(n, dot, vars)
+		}
+	case *parse.ActionNode:
+		// The actions that declare variables print nothing.
+		if v := w.pipe(node.Pipe, dot, vars); len(node.Pipe.Decl) == 0 {
+			w.use(v)
+		}
+	case *parse.IfNode:
+		// Only the truth of the pipeline is used, which doesn't need the fields.
+		w.pipe(node.Pipe, dot, vars)
+		w.walk(node.List, dot, copyVars(vars))
+		w.walk(node.ElseList, dot, copyVars(vars))
+	case *parse.WithNode:
+		inner := copyVars(vars)
+		v := w.pipe(node.Pipe, dot, inner)
+		w.walk(node.List, v, inner)
+		w.walk(node.ElseList, dot, copyVars(vars))
+	case *parse.RangeNode:
+		inner := copyVars(vars)
+		v := w.pipe(node.Pipe, dot, inner)
+		element := otherValue
+		if v == "Services" {
+			element = "Service"
+		} else {
+			w.use(v)
+		}
+		for _, decl := range node.Pipe.Decl {
+			// The element is bound to the last variable, and the index to the first one if there are two.
+			inner[decl.Ident[0]] = otherValue
+		}
+		if n := len(node.Pipe.Decl); n > 0 {
+			inner[node.Pipe.Decl[n-1].Ident[0]] = element
+		}
+		w.walk(node.List, element, inner)
+		w.walk(node.ElseList, dot, copyVars(vars))
+	case *parse.TemplateNode:
+		v := otherValue
+		if node.Pipe != nil {
+			v = w.pipe(node.Pipe, dot, vars)
+		}
+		key := node.Name + "\x00" + string(v)
+		tree := w.trees[node.Name]
+		if tree == nil || tree.Root == nil || w.walking[key] {
+			return
+		}
+		w.walking[key] = true
+		w.walk(tree.Root, v, map[string]value{"$": v})
+		delete(w.walking, key)
+	}
+}
+
+// pipe evaluates the pipeline, the result of every command is passed to the next one as the last argument,
+// and the declared variables are bound to the result.
+func (w *fieldWalker) pipe(pipe *parse.PipeNode, dot value, vars map[string]value) value {
+	result := otherValue
+	for i, cmd := range pipe.Cmds {
+		piped := result
+		if i == 0 {
+			piped = otherValue
+		}
+		result = w.command(cmd, piped, dot, vars)
+	}
+	for _, decl := range pipe.Decl {
+		vars[decl.Ident[0]] = result
+	}
+	return result
+}
+
+// command evaluates the command with the piped value of the previous command.
+func (w *fieldWalker) command(cmd *parse.CommandNode, piped value, dot value, vars map[string]value) value {
+	if len(cmd.Args) == 0 {
+		return otherValue
+	}
+	if ident, ok := cmd.Args[0].(*parse.IdentifierNode); ok {
+		metadataOnly := metadataFuncs[ident.Ident]
+		for _, arg := range cmd.Args[1:] {
+			if v := w.arg(arg, dot, vars); !metadataOnly {
+				w.use(v)
+			}
+		}
+		if !metadataOnly {
+			w.use(piped)
+		}
+		return otherValue
+	}
+	if len(cmd.Args) > 1 {
+		// Calling a method with arguments, like `{{ .Pod.GetLabels.Get "app" }}`.
+		for _, arg := range cmd.Args[1:] {
+			w.use(w.arg(arg, dot, vars))
+		}
+	}
+	return w.arg(cmd.Args[0], dot, vars)
+}
+
+// arg evaluates the argument of a command.
+func (w *fieldWalker) arg(node parse.Node, dot value, vars map[string]value) value {
+	switch node := node.(type) {
+	case *parse.DotNode:
+		return dot
+	case *parse.FieldNode:
+		return w.fieldChain(dot, node.Ident)
+	case *parse.VariableNode:
+		return w.fieldChain(vars[node.Ident[0]], node.Ident[1:])
+	case *parse.ChainNode:
+		return w.fieldChain(w.arg(node.Node, dot, vars), node.Field)
+	case *parse.PipeNode:
+		return w.pipe(node, dot, copyVars(vars))
+	}
+	return otherValue
+}
+
+// fieldChain evaluates the fields of the value, like `.Pod.Spec.NodeName` of the context.
+func (w *fieldWalker) fieldChain(v value, fields []string) value {
+	if len(fields) == 0 {
+		return v
+	}
+	if v == contextValue {
+		if !objectFields[fields[0]] {
+			return otherValue
+		}
+		v, fields = value(fields[0]), fields[1:]
+		if len(fields) == 0 {
+			return v
+		}
+	}
+	w.reference(v, fields[0])
+	return otherValue
+}
+
+func copyVars(vars map[string]value) map[string]value {
+	result := make(map[string]value, len(vars))
+	for k, v := range vars {
+		result[k] = v
+	}
+	return result
+}
+
+// strings returns all the strings in the exporter configurations, which contain the templates.
+func (c ExporterConfig) strings() (result []string) {
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch value := v.(type) {
+		case string:
+			result = append(result, value)
+		case ExporterConfig:
+			for _, item := range value {
+				walk(item)
+			}
+		case map[string]interface{}:
+			for _, item := range value {
+				walk(item)
+			}
+		case []interface{}:
+			for _, item := range value {
+				walk(item)
+			}
+		}
+	}
+	walk(c)
+	return result
+}
+
+// RegistryOptions returns the options of the registries of the clusters, the fields of the Pods, Services
+// and Nodes that are referenced by the templates and the filters are kept in the informers, as well as the
+// ones in `registry.keepFields`.
+func (config *Config) RegistryOptions() k8s.RegistryOptions {
+	keep := map[string]bool{}
+	for _, field := range config.Registry.KeepFields {
+		keep[field] = true
+	}
+	for _, exporter := range config.Exporters {
+		for _, s := range exporter.strings() {
+			for _, field := range referencedFields(s) {
+				keep[field] = true
+			}
+		}
+	}
+	for _, filter := range config.Filters {
+		if filter == nil {
+			continue
+		}
+		for path := range filter.Object {
+			field, ok := map[string]string{"spec": "Spec", "status": "Status"}[strings.SplitN(path, ".", 2)[0]]
+			if !ok {
+				continue
+			}
+			for kind := range keepableFields {
+				keep[kind+"."+field] = true
+			}
+		}
+	}
+
+	options := config.Registry.Options()
+	options.KeepFields = make([]string, 0, len(keep))
+	for field := range keep {
+		options.KeepFields = append(options.KeepFields, field)
+	}
+	sort.Strings(options.KeepFields)
+
+	return options
+}
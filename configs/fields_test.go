@@ -0,0 +1,132 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package configs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfig_RegistryOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name: "metadata only",
+			content: `
+exporters:
+  skywalking:
+    template:
+      source:
+        service: "{{ .Service.Name }}"
+        serviceInstance: "{{ .Pod.Name }}.{{ .Pod.Namespace }}"
+      message: "{{ label \"app\" .Pod }} {{ .Node.Labels }} {{ .Event.InvolvedObject.Kind }} {{ .Pod | annotation \"owner\" }}"
+`,
+			want: []string{},
+		},
+		{
+			name: "spec and status",
+			content: `
+exporters:
+  console:
+    template:
+      parameters:
+        node: "{{ .Pod.Spec.NodeName }}"
+        ready: "{{ .Node.Status.Conditions }}"
+`,
+			want: []string{"Node.Status", "Pod.Spec"},
+		},
+		{
+			name: "whole objects",
+			content: `
+exporters:
+  console:
+    template:
+      message: "{{ toJson .Pod }} {{ range .Services }}{{ .Spec.ClusterIP }}{{ end }} {{ range $i, $n := .Node.Spec.Taints }}{{ $n.Key }}{{ end }}"
+`,
+			want: []string{"Node.Spec", "Pod.Spec", "Pod.Status", "Service.Spec"},
+		},
+		{
+			name: "objects bound by with",
+			content: `
+exporters:
+  console:
+    template:
+      message: "{{ with .Pod }}{{ .Spec.NodeName }} {{ .Name }} {{ label \"app\" . }}{{ end }} {{ with .Service }}{{ . | annotation \"owner\" }}{{ else }}{{ .Node.Status.Phase }}{{ end }}"
+`,
+			want: []string{"Node.Status", "Pod.Spec"},
+		},
+		{
+			name: "objects bound to variables",
+			content: `
+exporters:
+  console:
+    template:
+      message: "{{ $p := .Pod }}{{ $p.Status.PodIP }} {{ $s := .Service }}{{ $s.Name }} {{ with $n := .Node }}{{ toJson $n }}{{ end }} {{ $.Pod.Name }}"
+`,
+			want: []string{"Node.Spec", "Node.Status", "Pod.Status"},
+		},
+		{
+			name: "objects passed to the defined templates",
+			content: `
+exporters:
+  console:
+    template:
+      message: "{{ define \"spec\" }}{{ .Spec }}{{ end }}{{ template \"spec\" .Service }}"
+`,
+			want: []string{"Service.Spec"},
+		},
+		{
+			name: "invalid templates",
+			content: `
+exporters:
+  console:
+    template:
+      message: "{{ .Pod.Name "
+`,
+			want: []string{"Node.Spec", "Node.Status", "Pod.Spec", "Pod.Status", "Service.Spec", "Service.Status"},
+		},
+		{
+			name: "filters and explicit fields",
+			content: `
+registry:
+  keepFields: ["Node.Spec"]
+filters:
+  - object:
+      status.phase: "Failed"
+      metadata.name: "reviews"
+`,
+			want: []string{"Node.Spec", "Node.Status", "Pod.Status", "Service.Status"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := Parse([]byte(tt.content))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := config.RegistryOptions().KeepFields; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RegistryOptions().KeepFields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package configs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is a problem found when validating the configurations.
+type Problem struct {
+	// Line is the line number in the configuration file where the problem is, 0 if unknown.
+	Line int
+	// Path is the path to the configuration item, like `filters[0].reason`.
+	Path string
+	Err  error
+}
+
+func (p Problem) Error() string {
+	if p.Path == "" {
+		return fmt.Sprintf("line %d: %v", p.Line, p.Err)
+	}
+	return fmt.Sprintf("line %d: %v: %v", p.Line, p.Path, p.Err)
+}
+
+// FieldError is an error of a field in the exporter-specific configurations,
+// Field is the dot-separated path of the field, like `template.source.service`.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ExporterValidator validates the configurations of the exporter with the given name,
+// it returns FieldError if the problem can be located to a field.
+type ExporterValidator func(name string, config ExporterConfig) []error
+
+// Validate validates the content of the configurations without connecting to the Kubernetes
+// clusters or the exporters' backends, it reports all the problems found, rather than only the first one.
+func Validate(content []byte, validateExporter ExporterValidator) []Problem {
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return []Problem{yamlProblem(err)}
+	}
+
+	v := &validator{root: &root}
+
+	config := &Config{}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return []Problem{yamlProblem(err)}
+		}
+		for _, e := range typeErr.Errors {
+			v.problems = append(v.problems, yamlProblem(errors.New(e)))
+		}
+	}
+
+	v.validateClusters(config.Clusters)
+	v.validateRegistry(config.Registry)
+	v.validateDeadLetter(config)
+	v.validateRedaction(config.Redaction)
+	v.validateFilters(config)
+	v.validateExporters(config, validateExporter)
+
+	sort.SliceStable(v.problems, func(i, j int) bool {
+		return v.problems[i].Line < v.problems[j].Line
+	})
+
+	return v.problems
+}
+
+type validator struct {
+	root     *yaml.Node
+	problems []Problem
+}
+
+func (v *validator) report(err error, path ...string) {
+	v.problems = append(v.problems, Problem{
+		Line: lineOf(v.root, path),
+		Path: formatPath(path),
+		Err:  err,
+	})
+}
+
+func (v *validator) validateClusters(clusters []*ClusterConfig) {
+	names := map[string]bool{}
+	for i, cluster := range clusters {
+		index := strconv.Itoa(i)
+		if cluster == nil {
+			v.report(errors.New("cluster cannot be empty"), "clusters", index)
+			continue
+		}
+		if names[cluster.Name] {
+			v.report(fmt.Errorf("cluster %q is defined more than once", cluster.Name), "clusters", index, "name")
+		}
+		names[cluster.Name] = true
+
+		if cluster.ResyncPeriod < 0 {
+			v.report(errors.New("resyncPeriod cannot be negative"), "clusters", index, "resyncPeriod")
+		}
+		if cluster.Kubeconfig != "" {
+			if _, err := os.Stat(cluster.Kubeconfig); err != nil {
+				v.report(err, "clusters", index, "kubeconfig")
+			}
+		}
+		if secret := cluster.Secret; secret != nil {
+			if secret.Namespace == "" {
+				v.report(errors.New("namespace of the secret cannot be empty"), "clusters", index, "secret")
+			}
+			if secret.Name == "" {
+				v.report(errors.New("name of the secret cannot be empty"), "clusters", index, "secret")
+			}
+		}
+	}
+}
+
+func (v *validator) validateRegistry(registry RegistryConfig) {
+	for i, kind := range registry.Kinds {
+		if strings.TrimSpace(kind) == "" {
+			v.report(errors.New("kind cannot be empty"), "registry", "kinds", strconv.Itoa(i))
+		}
+	}
+	for i, field := range registry.KeepFields {
+		if kind := strings.SplitN(field, ".", 2)[0]; !contains(keepableFields[kind], field) {
+			v.report(fmt.Errorf("field %q cannot be kept, it must be one of Pod.Spec, Pod.Status, Service.Spec, Service.Status, Node.Spec and Node.Status", field), "registry", "keepFields", strconv.Itoa(i))
+		}
+	}
+	if registry.CacheSize < 0 {
+		v.report(errors.New("cacheSize cannot be negative"), "registry", "cacheSize")
+	}
+	if registry.DeletedObjectTTL < 0 {
+		v.report(errors.New("deletedObjectTTL cannot be negative"), "registry", "deletedObjectTTL")
+	}
+}
+
+func (v *validator) validateDeadLetter(config *Config) {
+	deadLetter := config.DeadLetter
+	if deadLetter.File != "" && deadLetter.Exporter != "" {
+		v.report(errors.New("only one of file and exporter can be specified"), "deadLetter")
+	}
+	if deadLetter.File != "" {
+		if _, err := os.Stat(filepath.Dir(deadLetter.File)); err != nil {
+			v.report(err, "deadLetter", "file")
+		}
+	}
+	if deadLetter.Exporter != "" {
+		if _, ok := config.Exporters[deadLetter.Exporter]; !ok {
+			v.report(fmt.Errorf("exporter %q is not defined in the exporters section", deadLetter.Exporter), "deadLetter", "exporter")
+		}
+	}
+}
+
+func (v *validator) validateRedaction(redaction RedactionConfig) {
+	for i, preset := range redaction.Presets {
+		if _, ok := redactionPresets[preset]; !ok {
+			v.report(fmt.Errorf("preset %q is not one of bearerToken, basicAuth and awsKey", preset), "redaction", "presets", strconv.Itoa(i))
+		}
+	}
+	for i, rule := range redaction.Rules {
+		if err := rule.compile(); err != nil {
+			v.report(err, "redaction", "rules", strconv.Itoa(i), "pattern")
+		}
+	}
+}
+
+func (v *validator) validateFilters(config *Config) {
+	for i, filter := range config.Filters {
+		index := strconv.Itoa(i)
+		if filter == nil {
+			v.report(errors.New("filter cannot be empty"), "filters", index)
+			continue
+		}
+
+		for _, f := range []struct{ field, expr string }{
+			{"reason", filter.Reason},
+			{"message", filter.Message},
+			{"type", filter.Type},
+			{"action", filter.Action},
+			{"kind", filter.Kind},
+			{"namespace", filter.Namespace},
+			{"name", filter.Name},
+			{"service", filter.Service},
+			{"cluster", filter.Cluster},
+			{"workload", filter.Workload},
+		} {
+			if _, err := regexp.Compile(f.expr); err != nil {
+				v.report(fmt.Errorf("invalid regular expression: %w", err), "filters", index, f.field)
+			}
+		}
+		for path, expr := range filter.Object {
+			if _, err := regexp.Compile(expr); err != nil {
+				v.report(fmt.Errorf("invalid regular expression: %w", err), "filters", index, "object", path)
+			}
+		}
+
+		if len(filter.Exporters) == 0 {
+			v.report(errors.New("no exporter is specified"), "filters", index)
+		}
+		for j, name := range filter.Exporters {
+			if _, ok := config.Exporters[name]; !ok {
+				v.report(fmt.Errorf("exporter %q is not defined in the exporters section", name), "filters", index, "exporters", strconv.Itoa(j))
+			}
+		}
+	}
+}
+
+func (v *validator) validateExporters(config *Config, validateExporter ExporterValidator) {
+	if validateExporter == nil {
+		return
+	}
+
+	names := make([]string, 0, len(config.Exporters))
+	for name := range config.Exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, err := range validateExporter(name, config.Exporters[name]) {
+			path := []string{"exporters", name}
+
+			var fieldErr *FieldError
+			if errors.As(err, &fieldErr) {
+				path = append(path, strings.Split(fieldErr.Field, ".")...)
+				err = fieldErr.Err
+			}
+
+			v.report(err, path...)
+		}
+	}
+}
+
+var yamlLineRegExp = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+func yamlProblem(err error) Problem {
+	if match := yamlLineRegExp.FindStringSubmatch(err.Error()); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		return Problem{Line: line, Err: errors.New(match[2])}
+	}
+	return Problem{Err: err}
+}
+
+// lineOf returns the line of the deepest node found along the path, the path elements are
+// mapping keys (matched case-insensitively) or sequence indexes.
+func lineOf(root *yaml.Node, path []string) int {
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	line := node.Line
+	for _, element := range path {
+		var next *yaml.Node
+
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if key := node.Content[i]; strings.EqualFold(key.Value, element) {
+					line = key.Line
+					next = node.Content[i+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			if i, err := strconv.Atoi(element); err == nil && i >= 0 && i < len(node.Content) {
+				next = node.Content[i]
+				line = next.Line
+			}
+		}
+
+		if next == nil {
+			break
+		}
+		node = next
+	}
+
+	return line
+}
+
+// formatPath formats the path like `filters[0].exporters[1]`.
+func formatPath(path []string) string {
+	var b strings.Builder
+	for _, element := range path {
+		if _, err := strconv.Atoi(element); err == nil {
+			b.WriteString("[" + element + "]")
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString(".")
+		}
+		b.WriteString(element)
+	}
+	return b.String()
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
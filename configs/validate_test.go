@@ -0,0 +1,170 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	type problem struct {
+		line int
+		path string
+	}
+	tests := []struct {
+		name    string
+		content string
+		want    []problem
+	}{
+		{
+			name: "valid config",
+			content: `
+filters:
+  - reason: "Killing|Killed"
+    exporters:
+      - console
+exporters:
+  console:
+    template:
+      message: "{{ .Event.Message }}"
+`,
+			want: nil,
+		},
+		{
+			name: "invalid regular expressions and undefined exporter",
+			content: `
+filters:
+  - reason: "(Killing"
+    message: "[a-"
+    exporters:
+      - console
+      - undefined
+exporters:
+  console:
+`,
+			want: []problem{
+				{line: 3, path: "filters[0].reason"},
+				{line: 4, path: "filters[0].message"},
+				{line: 7, path: "filters[0].exporters[1]"},
+			},
+		},
+		{
+			name: "unknown field and wrong type",
+			content: `
+filters:
+  - reasn: "Killing"
+    minCount: abc
+    exporters:
+      - console
+exporters:
+  console:
+`,
+			want: []problem{
+				{line: 3},
+				{line: 4},
+			},
+		},
+		{
+			name: "duplicated clusters",
+			content: `
+clusters:
+  - name: prod
+  - name: prod
+    namespace: bookinfo
+    resyncPeriod: -1m
+`,
+			want: []problem{
+				{line: 4, path: "clusters[1].name"},
+				{line: 6, path: "clusters[1].resyncPeriod"},
+			},
+		},
+		{
+			name: "dead letter",
+			content: `
+deadLetter:
+  file: /not/existed/dead-letters.jsonl
+  exporter: undefined
+exporters:
+  console:
+`,
+			want: []problem{
+				{line: 2, path: "deadLetter"},
+				{line: 3, path: "deadLetter.file"},
+				{line: 4, path: "deadLetter.exporter"},
+			},
+		},
+		{
+			name: "redaction",
+			content: `
+redaction:
+  presets:
+    - bearerToken
+    - password
+  rules:
+    - pattern: "token=("
+    - replacement: "***"
+exporters:
+  console:
+`,
+			want: []problem{
+				{line: 5, path: "redaction.presets[1]"},
+				{line: 7, path: "redaction.rules[0].pattern"},
+				{line: 8, path: "redaction.rules[1].pattern"},
+			},
+		},
+		{
+			name: "exporter-specific problems",
+			content: `
+filters:
+  - exporters:
+      - console
+exporters:
+  console:
+    template:
+      message: "{{ end }}"
+`,
+			want: []problem{
+				{line: 8, path: "exporters.console.template.message"},
+			},
+		},
+	}
+	validateExporter := func(name string, config ExporterConfig) []error {
+		if strings.Contains(fmt.Sprint(config), "{{ end }}") {
+			return []error{&FieldError{Field: "template.message", Err: errors.New("unexpected {{end}}")}}
+		}
+		return nil
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := Validate([]byte(tt.content), validateExporter)
+			if len(problems) != len(tt.want) {
+				t.Fatalf("Validate() = %v, want %v problems", problems, len(tt.want))
+			}
+			for i, p := range problems {
+				if p.Line != tt.want[i].line || p.Path != tt.want[i].path {
+					t.Errorf("Validate()[%d] = %v, want line %v and path %q", i, p, tt.want[i].line, tt.want[i].path)
+				}
+			}
+		})
+	}
+}
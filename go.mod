@@ -8,7 +8,8 @@ require (
 	github.com/spf13/cobra v1.1.3
 	google.golang.org/grpc v1.40.0
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
-	k8s.io/api v0.20.5
-	k8s.io/client-go v0.20.5
+	k8s.io/api v0.24.2
+	k8s.io/apimachinery v0.24.2
+	k8s.io/client-go v0.24.2
 	skywalking.apache.org/repo/goapi v0.0.0-20220412071816-33e4ea2a99b4
 )
@@ -0,0 +1,63 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package filewatch
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"time"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
+)
+
+// Watch polls the file at the given interval, and calls onChange with the new content
+// whenever the content changes. The content is compared rather than the modification
+// time, so that the symlink swap of a mounted ConfigMap or Secret is also detected.
+// Watch blocks until the context is done.
+func Watch(ctx context.Context, path string, interval time.Duration, onChange func(content []byte)) {
+	last, err := os.ReadFile(path)
+	if err != nil {
+		logger.Log.Warnf("failed to read file %v. %+v", path, err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			content, err := os.ReadFile(path)
+			if err != nil {
+				logger.Log.Warnf("failed to read file %v. %+v", path, err)
+				continue
+			}
+			if bytes.Equal(content, last) {
+				continue
+			}
+
+			logger.Log.Debugf("file %v has been changed", path)
+
+			last = content
+			onChange(content)
+		}
+	}
+}
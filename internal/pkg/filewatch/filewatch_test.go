@@ -0,0 +1,123 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package filewatch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testInterval = 10 * time.Millisecond
+
+// watch starts watching the file and returns the channel of the changed contents.
+func watch(t *testing.T, path string) <-chan string {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	changes := make(chan string, 10)
+	go Watch(ctx, path, testInterval, func(content []byte) {
+		changes <- string(content)
+	})
+	// Waits for the initial content to be read.
+	time.Sleep(5 * testInterval)
+
+	return changes
+}
+
+func expectChange(t *testing.T, changes <-chan string, want string) {
+	t.Helper()
+	select {
+	case got := <-changes:
+		if got != want {
+			t.Errorf("changed content = %q, want %q", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("no change is detected, want %q", want)
+	}
+}
+
+func expectNoChange(t *testing.T, changes <-chan string) {
+	t.Helper()
+	select {
+	case got := <-changes:
+		t.Errorf("unexpected change %q", got)
+	case <-time.After(10 * testInterval):
+	}
+}
+
+func write(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write(t, path, "filters: []")
+	changes := watch(t, path)
+
+	write(t, path, "filters: []")
+	expectNoChange(t, changes)
+
+	write(t, path, "exporters: {}")
+	expectChange(t, changes, "exporters: {}")
+	expectNoChange(t, changes)
+}
+
+// TestWatch_symlinkSwap simulates how the kubelet updates a mounted ConfigMap: the file is a symlink
+// to ..data/config.yaml, and ..data is a symlink to a timestamped directory, which is atomically
+// replaced by the symlink to a new directory.
+func TestWatch_symlinkSwap(t *testing.T) {
+	dir := t.TempDir()
+	version := func(name, content string) {
+		if err := os.Mkdir(filepath.Join(dir, name), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		write(t, filepath.Join(dir, name, "config.yaml"), content)
+	}
+	swap := func(name string) {
+		if err := os.Symlink(name, filepath.Join(dir, "..data_tmp")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	version("..2024_01_01", "filters: []")
+	swap("..2024_01_01")
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.Symlink(filepath.Join("..data", "config.yaml"), path); err != nil {
+		t.Fatal(err)
+	}
+	changes := watch(t, path)
+
+	version("..2024_01_02", "filters: []")
+	swap("..2024_01_02")
+	expectNoChange(t, changes)
+
+	version("..2024_01_03", "exporters: {}")
+	swap("..2024_01_03")
+	expectChange(t, changes, "exporters: {}")
+}
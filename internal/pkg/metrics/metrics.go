@@ -0,0 +1,79 @@
+// Licensed to Apache Software Foundation (ASF) under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Apache Software Foundation (ASF) licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+//
+
+package metrics
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+	"time"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
+)
+
+var (
+	// ConfigReloads counts the configuration reloads, keyed by the outcome, "success" or "failure".
+	ConfigReloads = expvar.NewMap("config_reloads_total")
+	// ConfigLastReloadSuccess is 1 if the last configuration reload succeeded, 0 otherwise.
+	ConfigLastReloadSuccess = expvar.NewInt("config_last_reload_successful")
+	// ConfigLastReloadTime is the Unix timestamp of the last configuration reload.
+	ConfigLastReloadTime = expvar.NewInt("config_last_reload_timestamp_seconds")
+	// EventsDropped counts the events that are not exported, because they fail to be
+	// exported, or the exporters are stopped before exporting them.
+	EventsDropped = expvar.NewInt("events_dropped_total")
+)
+
+// DropEvents records the events that are not exported.
+func DropEvents(n int) {
+	EventsDropped.Add(int64(n))
+}
+
+// ConfigReloaded records the outcome of a configuration reload.
+func ConfigReloaded(err error) {
+	ConfigLastReloadTime.Set(time.Now().Unix())
+	if err != nil {
+		ConfigReloads.Add("failure", 1)
+		ConfigLastReloadSuccess.Set(0)
+	} else {
+		ConfigReloads.Add("success", 1)
+		ConfigLastReloadSuccess.Set(1)
+	}
+}
+
+// Serve exposes the metrics at "/debug/vars" of the address, until the context is done.
+func Serve(ctx context.Context, address string) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	server := &http.Server{Addr: address, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+
+		if err := server.Close(); err != nil {
+			logger.Log.Warnf("failed to close metrics server. %+v", err)
+		}
+	}()
+
+	logger.Log.Debugf("serving metrics at %v", address)
+
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Log.Errorf("failed to serve metrics. %+v", err)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"google.golang.org/grpc/metadata"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/configs"
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/filewatch"
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
+)
+
+// authenticationKey is the gRPC metadata key of the token that SkyWalking OAP server authenticates.
+const authenticationKey = "authentication"
+
+// AuthenticationConfig configures the token to authenticate with the OAP server,
+// only one of Token, TokenEnv and TokenFile can be specified.
+type AuthenticationConfig struct {
+	// Token is the token itself.
+	Token string `mapstructure:"token"`
+	// TokenEnv is the environment variable that contains the token.
+	TokenEnv string `mapstructure:"tokenEnv"`
+	// TokenFile is the file that contains the token, like a mounted Secret, it's re-read when it changes.
+	TokenFile string `mapstructure:"tokenFile"`
+}
+
+// authenticator attaches the token to the calls.
+type authenticator struct {
+	token atomic.Value
+}
+
+// newAuthenticator loads the token, and keeps reloading the token file until the context is done.
+func newAuthenticator(ctx context.Context, config *AuthenticationConfig) (*authenticator, error) {
+	auth := &authenticator{}
+
+	switch {
+	case config.Token != "":
+		auth.token.Store(config.Token)
+	case config.TokenEnv != "":
+		token := strings.TrimSpace(os.Getenv(config.TokenEnv))
+		if token == "" {
+			return nil, fmt.Errorf("environment variable %v of the authentication token is empty", config.TokenEnv)
+		}
+		auth.token.Store(token)
+	case config.TokenFile != "":
+		content, err := os.ReadFile(config.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		auth.token.Store(strings.TrimSpace(string(content)))
+
+		go filewatch.Watch(ctx, config.TokenFile, fileReloadInterval, func(content []byte) {
+			logger.Log.Infof("authentication token file %v has been changed, reloading", config.TokenFile)
+			auth.token.Store(strings.TrimSpace(string(content)))
+		})
+	default:
+		return nil, errors.New("authentication token cannot be empty")
+	}
+
+	return auth, nil
+}
+
+// attach returns the context that carries the token as the outgoing metadata.
+func (auth *authenticator) attach(ctx context.Context) context.Context {
+	if auth == nil {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, authenticationKey, auth.token.Load().(string))
+}
+
+// validate validates the authentication configurations.
+func (config *AuthenticationConfig) validate() (errs []error) {
+	specified := 0
+	for _, v := range []string{config.Token, config.TokenEnv, config.TokenFile} {
+		if v != "" {
+			specified++
+		}
+	}
+	if specified != 1 {
+		errs = append(errs, &configs.FieldError{Field: "authentication", Err: errors.New("one and only one of token, tokenEnv and tokenFile must be specified")})
+	}
+	if config.TokenFile != "" && !isFileExisted(config.TokenFile) {
+		errs = append(errs, &configs.FieldError{Field: "authentication.tokenFile", Err: fmt.Errorf("file %v does not exist", config.TokenFile)})
+	}
+	return errs
+}
@@ -25,8 +25,6 @@ import (
 
 	"fmt"
 
-	"time"
-
 	"github.com/sirupsen/logrus"
 	sw "skywalking.apache.org/repo/goapi/collect/event/v3"
 
@@ -34,100 +32,171 @@ import (
 
 	"github.com/apache/skywalking-kubernetes-event-exporter/configs"
 	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/metrics"
+	"github.com/apache/skywalking-kubernetes-event-exporter/pkg/k8s"
 )
 
 // Console Exporter exports the events into console logs, this exporter is typically
 // used for debugging.
 type Console struct {
+	deadLettering
+	redacting
+
 	config ConsoleConfig
+	retry  *RetryPolicy
 }
 
 type ConsoleConfig struct {
 	Template *EventTemplate `mapstructure:"template"`
+	// Workers is the number of the events rendered and exported concurrently, the events of
+	// the same involved object are always exported in order.
+	Workers int `mapstructure:"workers"`
+	// Retry is the retry policy of the events that fail to be exported.
+	Retry *RetryConfig `mapstructure:"retry"`
 }
 
 func init() {
 	s := &Console{}
-	RegisterExporter(s.Name(), s)
+	RegisterExporter(s.Name(), func() Exporter {
+		return &Console{}
+	})
 }
 
-func (exporter *Console) Init(context.Context) error {
+func (exporter *Console) Init(_ context.Context, c configs.ExporterConfig) error {
 	config := ConsoleConfig{}
 
-	if c := configs.GlobalConfig.Exporters[exporter.Name()]; c == nil {
+	if c == nil {
 		return fmt.Errorf("configs of %+v exporter cannot be empty", exporter.Name())
-	} else if marshal, err := json.Marshal(c); err != nil {
-		return err
-	} else if err := json.Unmarshal(marshal, &config); err != nil {
+	} else if err := decodeConfig(c, &config, false); err != nil {
 		return err
 	}
 
 	if err := config.Template.Init(); err != nil {
 		return err
 	}
+	retry, err := config.Retry.Policy()
+	if err != nil {
+		return err
+	}
 
 	exporter.config = config
+	exporter.retry = retry
 
 	return nil
 }
 
+func (exporter *Console) Validate(c configs.ExporterConfig) []error {
+	config := ConsoleConfig{}
+
+	errs, ok := decodeConfigForValidation(c, &config)
+	if !ok {
+		return errs
+	}
+
+	errs = append(errs, prefixFieldErrors("template", config.Template.parse())...)
+	errs = append(errs, validateWorkers(config.Workers)...)
+	return append(errs, prefixFieldErrors("retry", config.Retry.validate())...)
+}
+
 func (exporter *Console) Name() string {
 	return "console"
 }
 
-func (exporter *Console) Export(ctx context.Context, events chan *k8score.Event) {
+func (exporter *Console) Export(ctx context.Context, events chan *k8s.Event) {
 	logger.Log.Debugf("exporting events into %+v", exporter.Name())
 
+	workers := k8s.StartOrderedWorkers(ctx, exporter.config.Workers, func(kEvent *k8s.Event) {
+		swEvent, err := exporter.render(ctx, kEvent)
+		if err != nil {
+			logger.Log.Errorf("failed to render event %v for %v. %+v", kEvent.UID, exporter.Name(), err)
+			exporter.deadLetter(exporter.Name(), kEvent, nil, err)
+			return
+		}
+		exporter.export(ctx, kEvent, swEvent)
+	})
+
 	for {
 		select {
 		case <-ctx.Done():
+			metrics.DropEvents(workers.Stop())
 			logger.Log.Debugf("stopping exporter %+v", exporter.Name())
 			return
-		case kEvent := <-events:
+		case kEvent, ok := <-events:
+			if !ok {
+				metrics.DropEvents(workers.Stop())
+				logger.Log.Debugf("stopping exporter %+v, all events have been exported", exporter.Name())
+				return
+			}
 			if logger.Log.IsLevelEnabled(logrus.DebugLevel) {
 				if bytes, err := json.Marshal(kEvent); err == nil {
 					logger.Log.Debugf("exporting event to %v: %v", exporter.Name(), string(bytes))
 				}
 			}
 
-			t := sw.Type_Normal
-			if kEvent.Type == k8score.EventTypeWarning {
-				t = sw.Type_Error
-			}
-			swEvent := &sw.Event{
-				Uuid:      string(kEvent.UID),
-				Source:    &sw.Source{},
-				Name:      kEvent.Reason,
-				Type:      t,
-				Message:   kEvent.Message,
-				StartTime: kEvent.FirstTimestamp.UnixNano() / 1000000,
-				EndTime:   kEvent.LastTimestamp.UnixNano() / 1000000,
-			}
-			if exporter.config.Template != nil {
-				go func() {
-					renderCtx, cancel := context.WithTimeout(ctx, time.Minute)
-					done := exporter.config.Template.render(renderCtx, swEvent, kEvent)
-					select {
-					case <-done:
-						logger.Log.Debugf("rendered event is: %+v", swEvent)
-						exporter.export(swEvent)
-					case <-renderCtx.Done():
-						logger.Log.Debugf("rendered event is: %+v", swEvent)
-						exporter.export(swEvent)
-					}
-					cancel()
-				}()
-			} else {
-				exporter.export(swEvent)
+			if !workers.Dispatch(ctx, kEvent) {
+				metrics.DropEvents(1)
 			}
 		}
 	}
 }
 
-func (exporter *Console) export(swEvent *sw.Event) {
-	if bytes, err := json.Marshal(swEvent); err != nil {
+// render renders the SkyWalking event of the Kubernetes event, it fails if the template is not rendered in time.
+func (exporter *Console) render(ctx context.Context, kEvent *k8s.Event) (*sw.Event, error) {
+	t := sw.Type_Normal
+	if kEvent.Type == k8score.EventTypeWarning {
+		t = sw.Type_Error
+	}
+	swEvent := &sw.Event{
+		Uuid:      string(kEvent.UID),
+		Source:    &sw.Source{},
+		Name:      kEvent.Reason,
+		Type:      t,
+		Message:   kEvent.Message,
+		StartTime: kEvent.FirstTimestamp.UnixNano() / 1000000,
+		EndTime:   kEvent.LastTimestamp.UnixNano() / 1000000,
+	}
+	if exporter.config.Template == nil {
+		return swEvent, nil
+	}
+
+	renderCtx, cancel := context.WithTimeout(ctx, renderTimeout)
+	defer cancel()
+
+	select {
+	case <-exporter.config.Template.render(renderCtx, swEvent, kEvent):
+	case <-renderCtx.Done():
+		return nil, fmt.Errorf("event is not rendered: %w", renderCtx.Err())
+	}
+	exporter.redact(swEvent)
+	logger.Log.Debugf("rendered event is: %+v", swEvent)
+
+	return swEvent, nil
+}
+
+func (exporter *Console) export(ctx context.Context, kEvent *k8s.Event, swEvent *sw.Event) {
+	if err := exporter.retry.Do(ctx, func() error {
+		bytes, err := json.Marshal(swEvent)
+		if err != nil {
+			return err
+		}
+		logger.Log.Infoln(string(bytes))
+		return nil
+	}); err != nil {
 		logger.Log.Errorf("failed to send event to %+v, %+v", exporter.Name(), err)
-	} else {
+		exporter.deadLetter(exporter.Name(), kEvent, swEvent, err)
+	}
+}
+
+// Resend prints the rendered events of the dead letters, which are redacted again, in case they were
+// dead-lettered before the redaction was enabled.
+func (exporter *Console) Resend(_ context.Context, events []*sw.Event) (int, error) {
+	for i, swEvent := range events {
+		exporter.redact(swEvent)
+		bytes, err := json.Marshal(swEvent)
+		if err != nil {
+			return i, err
+		}
 		logger.Log.Infoln(string(bytes))
 	}
+	return len(events), nil
 }
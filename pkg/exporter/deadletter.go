@@ -0,0 +1,194 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package exporter
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	sw "skywalking.apache.org/repo/goapi/collect/event/v3"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/metrics"
+	"github.com/apache/skywalking-kubernetes-event-exporter/pkg/k8s"
+)
+
+// DeadLetter is an event that fails to be exported, with the reason of the failure.
+type DeadLetter struct {
+	// Exporter is the name of the exporter that fails to export the event.
+	Exporter string    `json:"exporter"`
+	Reason   string    `json:"reason"`
+	Time     time.Time `json:"time"`
+	Cluster  string    `json:"cluster,omitempty"`
+	// Event is the Kubernetes event, and Rendered is the event rendered by the exporter.
+	Event    *corev1.Event `json:"event"`
+	Rendered *sw.Event     `json:"rendered,omitempty"`
+}
+
+// DeadLetters receives the events that fail to be exported.
+type DeadLetters interface {
+	Send(letter *DeadLetter)
+}
+
+// DeadLetterExporter is implemented by the exporters that send the events failing to be exported to the dead letters.
+type DeadLetterExporter interface {
+	SetDeadLetters(deadLetters DeadLetters)
+}
+
+// Resender is implemented by the exporters that can resend the rendered events of the dead letters as they are,
+// the other exporters export the Kubernetes events of the dead letters again. Resend returns the number
+// of the leading events that are resent before the error, so that only the rest need to be resent again.
+type Resender interface {
+	Resend(ctx context.Context, events []*sw.Event) (resent int, err error)
+}
+
+// deadLettering is embedded into the exporters to implement DeadLetterExporter.
+type deadLettering struct {
+	deadLetters DeadLetters
+}
+
+func (d *deadLettering) SetDeadLetters(deadLetters DeadLetters) {
+	d.deadLetters = deadLetters
+}
+
+// deadLetter records the event as dropped, and sends it to the dead letters if they are configured.
+func (d *deadLettering) deadLetter(exporter string, kEvent *k8s.Event, swEvent *sw.Event, reason error) {
+	metrics.DropEvents(1)
+
+	if d.deadLetters == nil {
+		return
+	}
+	d.deadLetters.Send(&DeadLetter{
+		Exporter: exporter,
+		Reason:   reason.Error(),
+		Time:     time.Now(),
+		Cluster:  kEvent.Cluster,
+		Event:    kEvent.Event,
+		Rendered: swEvent,
+	})
+}
+
+// FileDeadLetters appends the dead letters to a file in JSON Lines, the dead letters are written
+// without buffering, so that they are kept even if the process exits without closing the file.
+type FileDeadLetters struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileDeadLetters returns the dead letters that are appended to the file.
+func NewFileDeadLetters(path string) (*FileDeadLetters, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileDeadLetters{file: file}, nil
+}
+
+func (d *FileDeadLetters) Send(letter *DeadLetter) {
+	bytes, err := json.Marshal(letter)
+	if err != nil {
+		logger.Log.Errorf("failed to encode the dead letter of event %v. %+v", letter.Event.UID, err)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, err := d.file.Write(append(bytes, '\n')); err != nil {
+		logger.Log.Errorf("failed to write the dead letter of event %v. %+v", letter.Event.UID, err)
+	}
+}
+
+// Close closes the file.
+func (d *FileDeadLetters) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.file.Close()
+}
+
+// ExporterDeadLetters sends the Kubernetes events of the dead letters to another exporter,
+// which exports the events from Events.
+type ExporterDeadLetters struct {
+	ctx    context.Context
+	lock   sync.RWMutex
+	closed bool
+	events chan *k8s.Event
+}
+
+// NewExporterDeadLetters returns the dead letters that are sent to another exporter until the context is done.
+func NewExporterDeadLetters(ctx context.Context) *ExporterDeadLetters {
+	return &ExporterDeadLetters{ctx: ctx, events: make(chan *k8s.Event)}
+}
+
+// Events returns the channel of the Kubernetes events of the dead letters, which is closed by Close.
+func (d *ExporterDeadLetters) Events() chan *k8s.Event {
+	return d.events
+}
+
+func (d *ExporterDeadLetters) Send(letter *DeadLetter) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	if d.closed {
+		logger.Log.Warnf("dead letters are closed, dropping event %v that fails to be exported by %v", letter.Event.UID, letter.Exporter)
+		return
+	}
+
+	select {
+	case d.events <- &k8s.Event{Event: letter.Event, Cluster: letter.Cluster}:
+	case <-d.ctx.Done():
+	}
+}
+
+// Close closes the events channel, the dead letters sent afterwards are dropped.
+func (d *ExporterDeadLetters) Close() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if !d.closed {
+		d.closed = true
+		close(d.events)
+	}
+}
+
+// ReadDeadLetters reads the dead letters in JSON Lines.
+func ReadDeadLetters(r io.Reader) ([]*DeadLetter, error) {
+	var letters []*DeadLetter
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		letter := &DeadLetter{}
+		if err := json.Unmarshal(scanner.Bytes(), letter); err != nil {
+			return nil, err
+		}
+		letters = append(letters, letter)
+	}
+
+	return letters, scanner.Err()
+}
@@ -0,0 +1,234 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package exporter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	sw "skywalking.apache.org/repo/goapi/collect/event/v3"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/configs"
+	"github.com/apache/skywalking-kubernetes-event-exporter/pkg/k8s"
+)
+
+// recordedDeadLetters records the dead letters.
+type recordedDeadLetters struct {
+	mu      sync.Mutex
+	letters []*DeadLetter
+}
+
+func (d *recordedDeadLetters) Send(letter *DeadLetter) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.letters = append(d.letters, letter)
+}
+
+func TestFileDeadLetters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+	deadLetters, err := NewFileDeadLetters(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*DeadLetter{
+		{
+			Exporter: "skywalking",
+			Reason:   "rpc error: code = Unavailable",
+			Time:     time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC),
+			Cluster:  "prod",
+			Event:    &corev1.Event{ObjectMeta: metav1.ObjectMeta{Name: "reviews.1", UID: "1"}, Reason: "Killing"},
+			Rendered: &sw.Event{Uuid: "1", Source: &sw.Source{Service: "reviews"}, Name: "Killing", Type: sw.Type_Error},
+		},
+		{
+			Exporter: "console",
+			Reason:   "json: unsupported value",
+			Time:     time.Date(2022, 6, 1, 0, 0, 1, 0, time.UTC),
+			Event:    &corev1.Event{ObjectMeta: metav1.ObjectMeta{Name: "reviews.2", UID: "2"}, Reason: "Started"},
+		},
+	}
+	for _, letter := range want {
+		deadLetters.Send(letter)
+	}
+	if err := deadLetters.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	got, err := ReadDeadLetters(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadDeadLetters() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSkyWalking_deadLetters(t *testing.T) {
+	server, conn := startEventServer(t)
+	server.err = status.Error(codes.InvalidArgument, "invalid event")
+
+	deadLetters := &recordedDeadLetters{}
+	exporter := &SkyWalking{
+		config:        SkyWalkingConfig{BatchSize: 2},
+		client:        sw.NewEventServiceClient(conn),
+		batchInterval: time.Hour,
+		callTimeout:   time.Second,
+	}
+	exporter.SetDeadLetters(deadLetters)
+
+	events := make(chan *k8s.Event)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		exporter.Export(context.Background(), events)
+	}()
+	for _, uid := range []string{"0", "1", "2"} {
+		events <- &k8s.Event{Cluster: "prod", Event: &corev1.Event{ObjectMeta: metav1.ObjectMeta{UID: types.UID(uid)}}}
+	}
+	close(events)
+	<-done
+
+	if len(deadLetters.letters) != 3 {
+		t.Fatalf("got %v dead letters, want 3", len(deadLetters.letters))
+	}
+	for i, letter := range deadLetters.letters {
+		if letter.Exporter != "skywalking" || letter.Cluster != "prod" || !strings.Contains(letter.Reason, "invalid event") {
+			t.Errorf("unexpected dead letter %+v", letter)
+		}
+		if uid := string(letter.Event.UID); letter.Rendered == nil || letter.Rendered.Uuid != uid || uid != []string{"0", "1", "2"}[i] {
+			t.Errorf("unexpected event %v of dead letter %v", uid, i)
+		}
+	}
+}
+
+func TestSkyWalking_deadLetters_backendDown(t *testing.T) {
+	defaultInitialBackoff, defaultMaxBackoff = time.Millisecond, time.Millisecond
+	defer func() {
+		defaultInitialBackoff, defaultMaxBackoff = 500*time.Millisecond, 30*time.Second
+	}()
+
+	server, conn := startEventServer(t)
+	server.err = status.Error(codes.Unavailable, "backend is down")
+
+	// The default retry policy gives up after the default attempts, rather than blocking the batch forever.
+	retry, err := (*RetryConfig)(nil).Policy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadLetters := &recordedDeadLetters{}
+	exporter := &SkyWalking{
+		config:        SkyWalkingConfig{BatchSize: 3},
+		client:        sw.NewEventServiceClient(conn),
+		batchInterval: time.Hour,
+		callTimeout:   time.Second,
+		retry:         retry,
+	}
+	exporter.SetDeadLetters(deadLetters)
+
+	events := make(chan *k8s.Event)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		exporter.Export(context.Background(), events)
+	}()
+	for _, uid := range []string{"0", "1", "2"} {
+		events <- &k8s.Event{Cluster: "prod", Event: &corev1.Event{ObjectMeta: metav1.ObjectMeta{UID: types.UID(uid)}}}
+	}
+
+	select {
+	case <-done:
+		t.Fatal("the exporter stopped before the events channel is closed")
+	case <-time.After(time.Second):
+	}
+	deadLetters.mu.Lock()
+	letters := len(deadLetters.letters)
+	deadLetters.mu.Unlock()
+	if letters != 3 {
+		t.Fatalf("got %v dead letters while the exporter is running, want 3", letters)
+	}
+	close(events)
+	<-done
+
+	server.mu.Lock()
+	calls := len(server.tokens)
+	server.mu.Unlock()
+	if calls != defaultMaxAttempts {
+		t.Errorf("got %v calls, want %v", calls, defaultMaxAttempts)
+	}
+	for _, letter := range deadLetters.letters {
+		if !strings.Contains(letter.Reason, "backend is down") {
+			t.Errorf("unexpected reason of dead letter %+v", letter)
+		}
+	}
+}
+
+func TestSkyWalking_Resend(t *testing.T) {
+	server, conn := startEventServer(t)
+	server.unavailable = 1
+
+	retry, err := (&RetryConfig{InitialBackoff: "1ms"}).Policy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	redaction := &configs.RedactionConfig{Presets: []string{"bearerToken"}}
+	if err := redaction.Init(); err != nil {
+		t.Fatal(err)
+	}
+	exporter := &SkyWalking{
+		config:      SkyWalkingConfig{BatchSize: 2},
+		client:      sw.NewEventServiceClient(conn),
+		callTimeout: time.Second,
+		retry:       retry,
+	}
+	exporter.SetRedaction(redaction)
+
+	// The first batch is retried after the transient failure, and the events dead-lettered
+	// before the redaction was enabled are redacted when they are resent.
+	resent, err := exporter.Resend(context.Background(), []*sw.Event{
+		{Uuid: "0", Message: "Authorization: Bearer abc.def"},
+		{Uuid: "1"},
+		{Uuid: "2"},
+	})
+	if err != nil || resent != 3 {
+		t.Fatalf("Resend() = %v, %v, want 3 events resent", resent, err)
+	}
+	if want := [][]string{{"0", "1"}, {"2"}}; !reflect.DeepEqual(server.batches, want) {
+		t.Errorf("received batches %v, want %v", server.batches, want)
+	}
+	if want := "Authorization: Bearer [REDACTED]"; server.messages[0] != want {
+		t.Errorf("resent message %q, want %q", server.messages[0], want)
+	}
+}
@@ -20,33 +20,132 @@
 package exporter
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"text/template"
 
-	v1 "k8s.io/api/core/v1"
+	sw "skywalking.apache.org/repo/goapi/collect/event/v3"
 
+	"github.com/apache/skywalking-kubernetes-event-exporter/configs"
 	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
 	"github.com/apache/skywalking-kubernetes-event-exporter/pkg/event"
+	"github.com/apache/skywalking-kubernetes-event-exporter/pkg/k8s"
 )
 
 type Exporter interface {
 	Name() string
-	Init(ctx context.Context) error
-	Export(ctx context.Context, events chan *v1.Event)
+	Init(ctx context.Context, config configs.ExporterConfig) error
+	Export(ctx context.Context, events chan *k8s.Event)
 }
 
-var exporters = map[string]Exporter{}
+// Factory creates a new instance of an exporter.
+type Factory func() Exporter
 
-func RegisterExporter(name string, exporter Exporter) {
+var exporters = map[string]Factory{}
+
+func RegisterExporter(name string, factory Factory) {
 	if _, ok := exporters[name]; ok {
 		logger.Log.Panicf("exporter with name %v has already existed", name)
 	}
 
-	exporters[name] = exporter
+	exporters[name] = factory
 }
 
+// GetExporter returns a new instance of the exporter with the given name,
+// or nil if there is no such exporter.
 func GetExporter(name string) Exporter {
-	return exporters[name]
+	if factory, ok := exporters[name]; ok {
+		return factory()
+	}
+	return nil
+}
+
+// Validator is implemented by the exporters that can validate their configurations
+// without connecting to their backends.
+type Validator interface {
+	Validate(config configs.ExporterConfig) []error
+}
+
+// Validate validates the configurations of the exporter with the given name,
+// it's a configs.ExporterValidator.
+func Validate(name string, config configs.ExporterConfig) []error {
+	exporter := GetExporter(name)
+	if exporter == nil {
+		return []error{fmt.Errorf("exporter %q is not supported", name)}
+	}
+	if config == nil {
+		return []error{fmt.Errorf("configs of %+v exporter cannot be empty", name)}
+	}
+	if validator, ok := exporter.(Validator); ok {
+		return validator.Validate(config)
+	}
+	return nil
+}
+
+// decodeConfig decodes the exporter-specific configurations into v,
+// unknown fields are rejected if strict is true.
+func decodeConfig(c configs.ExporterConfig, v interface{}, strict bool) error {
+	marshal, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(marshal))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return &configs.FieldError{Field: typeErr.Field, Err: fmt.Errorf("cannot be %v", typeErr.Value)}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// decodeConfigForValidation decodes the configurations like decodeConfig, but it reports the
+// unknown fields and keeps decoding the known ones so that they can be validated further,
+// ok is false if the configurations cannot be decoded at all.
+func decodeConfigForValidation(c configs.ExporterConfig, v interface{}) (errs []error, ok bool) {
+	if err := decodeConfig(c, v, true); err != nil {
+		var fieldErr *configs.FieldError
+		if errors.As(err, &fieldErr) {
+			return append(errs, err), false
+		}
+		errs = append(errs, errors.New(strings.TrimPrefix(err.Error(), "json: ")))
+		if err := decodeConfig(c, v, false); err != nil {
+			return errs, false
+		}
+	}
+	return errs, true
+}
+
+// prefixFieldErrors prefixes the fields of the configs.FieldError with the given prefix.
+func prefixFieldErrors(prefix string, errs []error) []error {
+	for i, err := range errs {
+		var fieldErr *configs.FieldError
+		if errors.As(err, &fieldErr) {
+			errs[i] = &configs.FieldError{Field: prefix + "." + fieldErr.Field, Err: fieldErr.Err}
+		} else {
+			errs[i] = &configs.FieldError{Field: prefix, Err: err}
+		}
+	}
+	return errs
+}
+
+// validateWorkers validates the number of the workers that render and export the events.
+func validateWorkers(workers int) []error {
+	if workers < 0 {
+		return []error{&configs.FieldError{Field: "workers", Err: errors.New("workers cannot be negative")}}
+	}
+	return nil
 }
 
 type SourceTemplate struct {
@@ -60,30 +159,83 @@ type EventTemplate struct {
 	sourceTemplate  SourceTemplate
 	Message         string `mapstructure:"message"`
 	messageTemplate *template.Template
+	Name            string `mapstructure:"name"`
+	nameTemplate    *template.Template
+	// Type is rendered and then mapped with Types into `Normal` or `Error`,
+	// the type of the Kubernetes event is mapped if Type is empty.
+	Type          string `mapstructure:"type"`
+	typeTemplate  *template.Template
+	Types         map[string]string `mapstructure:"types"`
+	Layer         string            `mapstructure:"layer"`
+	layerTemplate *template.Template
+	// Parameters are rendered into the parameters of the SkyWalking event, the empty ones are omitted.
+	Parameters         map[string]string `mapstructure:"parameters"`
+	parameterTemplates map[string]*template.Template
 }
 
-func (tmplt *EventTemplate) Init() (err error) {
-	if tmplt.Message != "" {
-		if tmplt.messageTemplate, err = template.New("EventMessageTemplate").Parse(tmplt.Message); err != nil {
-			return err
-		}
+func (tmplt *EventTemplate) Init() error {
+	if errs := tmplt.parse(); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// parse parses all the templates and returns all the errors, the errors are of type configs.FieldError.
+func (tmplt *EventTemplate) parse() (errs []error) {
+	if tmplt == nil {
+		return nil
 	}
 
-	if t := tmplt.Source.Service; t != "" {
-		if tmplt.sourceTemplate.serviceTemplate, err = template.New("EventSourceServiceTemplate").Parse(t); err != nil {
-			return err
+	parse := func(field, name, text string, t **template.Template) {
+		if text == "" {
+			return
+		}
+		var err error
+		if *t, err = template.New(name).Funcs(funcMap).Parse(text); err != nil {
+			errs = append(errs, &configs.FieldError{Field: field, Err: err})
 		}
 	}
-	if t := tmplt.Source.ServiceInstance; t != "" {
-		if tmplt.sourceTemplate.serviceInstanceTemplate, err = template.New("EventServiceInstanceTemplate").Parse(t); err != nil {
-			return err
+
+	parse("message", "EventMessageTemplate", tmplt.Message, &tmplt.messageTemplate)
+	parse("source.service", "EventSourceServiceTemplate", tmplt.Source.Service, &tmplt.sourceTemplate.serviceTemplate)
+	parse("source.serviceInstance", "EventServiceInstanceTemplate", tmplt.Source.ServiceInstance, &tmplt.sourceTemplate.serviceInstanceTemplate)
+	parse("source.endpoint", "EventEndpointTemplate", tmplt.Source.Endpoint, &tmplt.sourceTemplate.endpointTemplate)
+	parse("name", "EventNameTemplate", tmplt.Name, &tmplt.nameTemplate)
+	parse("type", "EventTypeTemplate", tmplt.Type, &tmplt.typeTemplate)
+	parse("layer", "EventLayerTemplate", tmplt.Layer, &tmplt.layerTemplate)
+
+	tmplt.parameterTemplates = map[string]*template.Template{}
+	for _, key := range sortedKeys(tmplt.Parameters) {
+		var t *template.Template
+		parse("parameters."+key, "EventParameterTemplate", tmplt.Parameters[key], &t)
+		if t != nil {
+			tmplt.parameterTemplates[key] = t
 		}
 	}
-	if t := tmplt.Source.Endpoint; t != "" {
-		if tmplt.sourceTemplate.endpointTemplate, err = template.New("EventEndpointTemplate").Parse(t); err != nil {
-			return err
+
+	for _, key := range sortedKeys(tmplt.Types) {
+		if _, ok := sw.Type_value[tmplt.Types[key]]; !ok {
+			errs = append(errs, &configs.FieldError{Field: "types." + key, Err: fmt.Errorf("type %q is not one of Normal and Error", tmplt.Types[key])})
 		}
 	}
 
-	return err
+	return errs
+}
+
+// eventType maps the rendered type into the type of the SkyWalking event, ok is false if it's not a valid type.
+func (tmplt *EventTemplate) eventType(rendered string) (t sw.Type, ok bool) {
+	if mapped, found := tmplt.Types[rendered]; found {
+		rendered = mapped
+	}
+	value, ok := sw.Type_value[rendered]
+	return sw.Type(value), ok
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
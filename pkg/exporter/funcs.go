@@ -0,0 +1,199 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package exporter
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/pkg/k8s"
+)
+
+// funcMap is the function library that is available in all the templates of the exporters,
+// the piped value is always the last argument, so functions can be chained like
+// `{{ .Pod.Name | trimPodHash | lower }}`.
+var funcMap = template.FuncMap{
+	"lower":        strings.ToLower,
+	"upper":        strings.ToUpper,
+	"trim":         strings.TrimSpace,
+	"trimPrefix":   func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix":   func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":      func(old, replacement, s string) string { return strings.ReplaceAll(s, old, replacement) },
+	"contains":     func(substr, s string) bool { return strings.Contains(s, substr) },
+	"hasPrefix":    func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"hasSuffix":    func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+	"split":        func(sep, s string) []string { return strings.Split(s, sep) },
+	"join":         func(sep string, elems []string) string { return strings.Join(elems, sep) },
+	"truncate":     truncate,
+	"regexMatch":   regexMatch,
+	"regexReplace": regexReplace,
+	"default":      defaultValue,
+	"label":        label,
+	"annotation":   annotation,
+	"field":        field,
+	"trimPodHash":  trimPodHash,
+	"toJson":       toJSON,
+	"formatTime":   formatTime,
+	"now":          time.Now,
+}
+
+// truncate truncates s to at most n runes.
+func truncate(n int, s string) string {
+	if r := []rune(s); n >= 0 && len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+var regexps sync.Map // map[string]*regexp.Regexp
+
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	if r, ok := regexps.Load(pattern); ok {
+		return r.(*regexp.Regexp), nil
+	}
+	r, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexps.Store(pattern, r)
+	return r, nil
+}
+
+// regexMatch reports whether s contains any match of the regular expression pattern.
+func regexMatch(pattern, s string) (bool, error) {
+	r, err := compileRegexp(pattern)
+	if err != nil {
+		return false, err
+	}
+	return r.MatchString(s), nil
+}
+
+// regexReplace replaces the matches of the regular expression pattern in s with replacement,
+// inside replacement, `$1` stands for the first submatch, like regexp.Regexp.ReplaceAllString.
+func regexReplace(pattern, replacement, s string) (string, error) {
+	r, err := compileRegexp(pattern)
+	if err != nil {
+		return "", err
+	}
+	return r.ReplaceAllString(s, replacement), nil
+}
+
+// defaultValue returns d if v is nil or the zero value of its type, otherwise v.
+func defaultValue(d, v interface{}) interface{} {
+	if v == nil {
+		return d
+	}
+	if rv := reflect.ValueOf(v); rv.IsZero() {
+		return d
+	}
+	return v
+}
+
+// label returns the value of the label key of the Kubernetes object, or empty string
+// if the object doesn't have the label or it's not a Kubernetes object.
+func label(key string, obj interface{}) string {
+	if accessor, err := meta.Accessor(obj); err == nil {
+		return accessor.GetLabels()[key]
+	}
+	return ""
+}
+
+// field returns the field of the Kubernetes object at the dot-separated path, like
+// `{{ field "spec.storageClassName" .Object }}`, or empty string if the field doesn't exist.
+func field(path string, obj interface{}) string {
+	if o, ok := obj.(*k8s.Object); ok {
+		return k8s.FieldOf(o, path)
+	}
+	if o, ok := obj.(runtime.Object); ok && reflect.ValueOf(o).Kind() == reflect.Ptr && !reflect.ValueOf(o).IsNil() {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(o)
+		if err != nil {
+			return ""
+		}
+		return k8s.FieldOf(&k8s.Object{Object: content}, path)
+	}
+	return ""
+}
+
+// annotation returns the value of the annotation key of the Kubernetes object, or empty string
+// if the object doesn't have the annotation or it's not a Kubernetes object.
+func annotation(key string, obj interface{}) string {
+	if accessor, err := meta.Accessor(obj); err == nil {
+		return accessor.GetAnnotations()[key]
+	}
+	return ""
+}
+
+// podHashRegExp matches the suffixes generated by the controllers, i.e. the optional pod template hash
+// of a ReplicaSet and the random suffix of a Pod, both are composed of the characters in
+// https://github.com/kubernetes/apimachinery/blob/master/pkg/util/rand/rand.go
+var podHashRegExp = regexp.MustCompile(`(-[bcdfghjklmnpqrstvwxz2456789]{6,10})?-[bcdfghjklmnpqrstvwxz2456789]{5}$`)
+
+// trimPodHash trims the generated suffixes from the pod name, for example,
+// `reviews-v1-545db77b95-2mbvx` becomes `reviews-v1`, and `fluentd-x7k2p` becomes `fluentd`.
+func trimPodHash(name string) string {
+	return podHashRegExp.ReplaceAllString(name, "")
+}
+
+func toJSON(v interface{}) (string, error) {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+// formatTime formats the time with the layout, t can be a time.Time or a Kubernetes time
+// like `.Event.LastTimestamp`, the zero time is formatted as empty string.
+func formatTime(layout string, t interface{}) string {
+	var tm time.Time
+	switch v := t.(type) {
+	case time.Time:
+		tm = v
+	case *time.Time:
+		if v != nil {
+			tm = *v
+		}
+	case metav1.Time:
+		tm = v.Time
+	case *metav1.Time:
+		if v != nil {
+			tm = v.Time
+		}
+	case metav1.MicroTime:
+		tm = v.Time
+	case *metav1.MicroTime:
+		if v != nil {
+			tm = v.Time
+		}
+	}
+	if tm.IsZero() {
+		return ""
+	}
+	return tm.Format(layout)
+}
@@ -0,0 +1,117 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package exporter
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/pkg/k8s"
+)
+
+func TestFuncMap(t *testing.T) {
+	lastTimestamp := metav1.NewTime(time.Date(2021, 8, 1, 10, 20, 30, 0, time.UTC))
+	ctx := k8s.TemplateContext{
+		Cluster: "prod",
+		Pod: &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "reviews-v1-545db77b95-2mbvx",
+				Labels:      map[string]string{"app": "reviews"},
+				Annotations: map[string]string{"owner": "team-a"},
+			},
+		},
+		Service: &corev1.Service{},
+		Object: &k8s.Object{Object: map[string]interface{}{
+			"kind": "PersistentVolumeClaim",
+			"spec": map[string]interface{}{"storageClassName": "gp2", "volumeMode": nil},
+			"status": map[string]interface{}{
+				"capacity": map[string]interface{}{"storage": "10Gi"},
+			},
+		}},
+		Event: &corev1.Event{
+			Reason:        "Killing",
+			Message:       "  Stopping container reviews  ",
+			LastTimestamp: lastTimestamp,
+		},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{name: "lower", template: `{{ .Event.Reason | lower }}`, want: "killing"},
+		{name: "upper", template: `{{ .Cluster | upper }}`, want: "PROD"},
+		{name: "trim", template: `{{ .Event.Message | trim }}`, want: "Stopping container reviews"},
+		{name: "trimPrefix", template: `{{ .Pod.Name | trimPrefix "reviews-" }}`, want: "v1-545db77b95-2mbvx"},
+		{name: "trimSuffix", template: `{{ .Pod.Name | trimSuffix "-2mbvx" }}`, want: "reviews-v1-545db77b95"},
+		{name: "replace", template: `{{ .Pod.Name | replace "-" "_" }}`, want: "reviews_v1_545db77b95_2mbvx"},
+		{name: "contains", template: `{{ .Event.Message | contains "container" }}`, want: "true"},
+		{name: "hasPrefix", template: `{{ .Pod.Name | hasPrefix "details" }}`, want: "false"},
+		{name: "hasSuffix", template: `{{ .Pod.Name | hasSuffix "2mbvx" }}`, want: "true"},
+		{name: "split and join", template: `{{ .Pod.Name | split "-" | join "." }}`, want: "reviews.v1.545db77b95.2mbvx"},
+		{name: "truncate", template: `{{ .Pod.Name | truncate 7 }}`, want: "reviews"},
+		{name: "truncate short string", template: `{{ .Cluster | truncate 10 }}`, want: "prod"},
+		{name: "regexMatch", template: `{{ .Event.Reason | regexMatch "^Kill" }}`, want: "true"},
+		{name: "regexMatch invalid pattern", template: `{{ .Event.Reason | regexMatch "(" }}`, wantErr: true},
+		{name: "regexReplace", template: `{{ .Pod.Name | regexReplace "^(\\w+)-.*$" "$1" }}`, want: "reviews"},
+		{name: "default of empty value", template: `{{ .Service.Name | default "unknown" }}`, want: "unknown"},
+		{name: "default of non-empty value", template: `{{ .Cluster | default "unknown" }}`, want: "prod"},
+		{name: "label", template: `{{ label "app" .Pod }}`, want: "reviews"},
+		{name: "label absent", template: `{{ label "version" .Pod }}`, want: ""},
+		{name: "label of non-object", template: `{{ label "app" .Cluster }}`, want: ""},
+		{name: "field", template: `{{ field "spec.storageClassName" .Object }}`, want: "gp2"},
+		{name: "field nested", template: `{{ .Object | field "status.capacity.storage" }}`, want: "10Gi"},
+		{name: "field absent", template: `{{ field "spec.volumeName" .Object | default "unbound" }}`, want: "unbound"},
+		{name: "field null", template: `{{ field "spec.volumeMode" .Object }}`, want: ""},
+		{name: "field of typed object", template: `{{ field "metadata.name" .Pod }}`, want: "reviews-v1-545db77b95-2mbvx"},
+		{name: "annotation", template: `{{ .Pod | annotation "owner" }}`, want: "team-a"},
+		{name: "trimPodHash of Deployment pod", template: `{{ .Pod.Name | trimPodHash }}`, want: "reviews-v1"},
+		{name: "trimPodHash of DaemonSet pod", template: `{{ "fluentd-x7k2p" | trimPodHash }}`, want: "fluentd"},
+		{name: "trimPodHash of StatefulSet pod", template: `{{ "mysql-0" | trimPodHash }}`, want: "mysql-0"},
+		{name: "toJson", template: `{{ .Pod.Labels | toJson }}`, want: `{"app":"reviews"}`},
+		{name: "formatTime", template: `{{ .Event.LastTimestamp | formatTime "2006-01-02T15:04:05Z07:00" }}`, want: "2021-08-01T10:20:30Z"},
+		{name: "formatTime of zero time", template: `{{ .Event.FirstTimestamp | formatTime "2006-01-02" }}`, want: ""},
+		{name: "now", template: `{{ now | formatTime "2006" | len }}`, want: "4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New(tt.name).Funcs(funcMap).Parse(tt.template)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			var buf bytes.Buffer
+			err = tmpl.Execute(&buf, ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := buf.String(); !tt.wantErr && got != tt.want {
+				t.Errorf("Execute() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
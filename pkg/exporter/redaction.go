@@ -0,0 +1,59 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package exporter
+
+import (
+	sw "skywalking.apache.org/repo/goapi/collect/event/v3"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/configs"
+)
+
+// RedactingExporter is implemented by the exporters that redact the sensitive data in the rendered events.
+type RedactingExporter interface {
+	SetRedaction(redaction *configs.RedactionConfig)
+}
+
+// redacting is embedded into the exporters to implement RedactingExporter.
+type redacting struct {
+	redaction *configs.RedactionConfig
+}
+
+func (r *redacting) SetRedaction(redaction *configs.RedactionConfig) {
+	r.redaction = redaction
+}
+
+// redact redacts the rendered fields of the event, the message of the Kubernetes event
+// has been redacted before it's exported, but the templates may render other sensitive data.
+func (r *redacting) redact(swEvent *sw.Event) {
+	if !r.redaction.Enabled() {
+		return
+	}
+
+	swEvent.Message = r.redaction.Redact(swEvent.Message)
+	swEvent.Name = r.redaction.Redact(swEvent.Name)
+	if source := swEvent.Source; source != nil {
+		source.Service = r.redaction.Redact(source.Service)
+		source.ServiceInstance = r.redaction.Redact(source.ServiceInstance)
+		source.Endpoint = r.redaction.Redact(source.Endpoint)
+	}
+	for key, value := range swEvent.Parameters {
+		swEvent.Parameters[key] = r.redaction.Redact(value)
+	}
+}
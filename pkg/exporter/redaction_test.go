@@ -0,0 +1,56 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package exporter
+
+import (
+	"reflect"
+	"testing"
+
+	sw "skywalking.apache.org/repo/goapi/collect/event/v3"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/configs"
+)
+
+func TestRedacting_redact(t *testing.T) {
+	redaction := &configs.RedactionConfig{Rules: []configs.RedactionRule{{Pattern: "s3cr3t"}}}
+	if err := redaction.Init(); err != nil {
+		t.Fatal(err)
+	}
+	r := &redacting{}
+	r.SetRedaction(redaction)
+
+	swEvent := &sw.Event{
+		Name:       "s3cr3t",
+		Message:    "token s3cr3t is rejected",
+		Source:     &sw.Source{Service: "s3cr3t", ServiceInstance: "s3cr3t", Endpoint: "/login?token=s3cr3t"},
+		Parameters: map[string]string{"env": "TOKEN=s3cr3t", "node": "node-1"},
+	}
+	r.redact(swEvent)
+
+	want := &sw.Event{
+		Name:       "[REDACTED]",
+		Message:    "token [REDACTED] is rejected",
+		Source:     &sw.Source{Service: "[REDACTED]", ServiceInstance: "[REDACTED]", Endpoint: "/login?token=[REDACTED]"},
+		Parameters: map[string]string{"env": "TOKEN=[REDACTED]", "node": "node-1"},
+	}
+	if !reflect.DeepEqual(swEvent, want) {
+		t.Errorf("redacted event = %+v, want %+v", swEvent, want)
+	}
+}
@@ -24,17 +24,24 @@ import (
 	"context"
 	"encoding/json"
 	"text/template"
+	"time"
 
 	"github.com/sirupsen/logrus"
-	k8score "k8s.io/api/core/v1"
 	sw "skywalking.apache.org/repo/goapi/collect/event/v3"
 
 	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
 	"github.com/apache/skywalking-kubernetes-event-exporter/pkg/k8s"
 )
 
-func (tmplt *EventTemplate) render(ctx context.Context, swEvent *sw.Event, kEvent *k8score.Event) chan bool {
-	done := make(chan bool)
+// renderTimeout is how long to wait for an event to be rendered, including resolving its template context.
+var renderTimeout = time.Minute
+
+// render renders the templates into swEvent in the background, the returned channel receives when the
+// rendering is done, and never receives if the context is done before the template context is resolved.
+// The channel is buffered so that the rendering never blocks when the caller stops waiting, in which case
+// swEvent may still be written, so the caller must not use it anymore.
+func (tmplt *EventTemplate) render(ctx context.Context, swEvent *sw.Event, kEvent *k8s.Event) <-chan struct{} {
+	done := make(chan struct{}, 1)
 
 	go func() {
 		var templateCtx k8s.TemplateContext
@@ -42,7 +49,6 @@ func (tmplt *EventTemplate) render(ctx context.Context, swEvent *sw.Event, kEven
 		select {
 		case templateCtx = <-k8s.Registry.GetContext(ctx, kEvent):
 		case <-ctx.Done():
-			done <- true
 			return
 		}
 
@@ -83,8 +89,40 @@ func (tmplt *EventTemplate) render(ctx context.Context, swEvent *sw.Event, kEven
 		if err := render(tmplt.sourceTemplate.endpointTemplate, &swEvent.Source.Endpoint); err != nil {
 			logger.Log.Debugf("failed to render endpoin template, using the default event content. %+v", err)
 		}
+		if err := render(tmplt.nameTemplate, &swEvent.Name); err != nil {
+			logger.Log.Debugf("failed to render name template, using the default event content. %+v", err)
+		}
+		if err := render(tmplt.layerTemplate, &swEvent.Layer); err != nil {
+			logger.Log.Debugf("failed to render layer template, using the default event content. %+v", err)
+		}
+
+		eventType := kEvent.Type
+		if err := render(tmplt.typeTemplate, &eventType); err != nil {
+			logger.Log.Debugf("failed to render type template, using the default event content. %+v", err)
+		} else if tmplt.typeTemplate != nil || len(tmplt.Types) > 0 {
+			if t, ok := tmplt.eventType(eventType); ok {
+				swEvent.Type = t
+			} else {
+				logger.Log.Debugf("type %v is not mapped to a SkyWalking event type, using the default event content", eventType)
+			}
+		}
+
+		for key, t := range tmplt.parameterTemplates {
+			var value string
+			if err := render(t, &value); err != nil {
+				logger.Log.Debugf("failed to render parameter %v template. %+v", key, err)
+				continue
+			}
+			if value == "" {
+				continue
+			}
+			if swEvent.Parameters == nil {
+				swEvent.Parameters = map[string]string{}
+			}
+			swEvent.Parameters[key] = value
+		}
 
-		done <- true
+		done <- struct{}{}
 	}()
 
 	return done
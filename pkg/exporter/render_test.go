@@ -0,0 +1,184 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package exporter
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	sw "skywalking.apache.org/repo/goapi/collect/event/v3"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/configs"
+	"github.com/apache/skywalking-kubernetes-event-exporter/pkg/k8s"
+)
+
+func TestEventTemplate_render(t *testing.T) {
+	kEvent := &k8s.Event{
+		Cluster: "prod",
+		Event: &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Namespace: "default", Name: "reviews.1"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Node", Name: "node-1"},
+			Reason:         "NodeNotReady",
+			Type:           corev1.EventTypeNormal,
+			Count:          3,
+		},
+	}
+
+	tests := []struct {
+		name     string
+		template *EventTemplate
+		want     *sw.Event
+	}{
+		{
+			name:     "defaults",
+			template: &EventTemplate{},
+			want:     &sw.Event{Source: &sw.Source{}, Name: "NodeNotReady", Type: sw.Type_Normal, Layer: k8sLayerName},
+		},
+		{
+			name: "name and layer",
+			template: &EventTemplate{
+				Name:  "{{ .Event.Reason | lower }}",
+				Layer: "{{ if eq .Event.InvolvedObject.Kind \"Node\" }}OS_LINUX{{ end }}",
+			},
+			want: &sw.Event{Source: &sw.Source{}, Name: "nodenotready", Type: sw.Type_Normal, Layer: "OS_LINUX"},
+		},
+		{
+			name: "type mapped from the event type",
+			template: &EventTemplate{
+				Types: map[string]string{"Normal": "Error"},
+			},
+			want: &sw.Event{Source: &sw.Source{}, Name: "NodeNotReady", Type: sw.Type_Error, Layer: k8sLayerName},
+		},
+		{
+			name: "type rendered and mapped",
+			template: &EventTemplate{
+				Type:  "{{ .Event.Reason }}",
+				Types: map[string]string{"NodeNotReady": "Error"},
+			},
+			want: &sw.Event{Source: &sw.Source{}, Name: "NodeNotReady", Type: sw.Type_Error, Layer: k8sLayerName},
+		},
+		{
+			name: "type not mapped",
+			template: &EventTemplate{
+				Type: "{{ .Event.Reason }}",
+			},
+			want: &sw.Event{Source: &sw.Source{}, Name: "NodeNotReady", Type: sw.Type_Normal, Layer: k8sLayerName},
+		},
+		{
+			name: "parameters",
+			template: &EventTemplate{
+				Parameters: map[string]string{
+					"namespace": "{{ .Event.Namespace }}",
+					"kind":      "{{ .Event.InvolvedObject.Kind }}",
+					"count":     "{{ .Event.Count }}",
+					"node":      "{{ .Node.Name }}",
+				},
+			},
+			want: &sw.Event{
+				Source:     &sw.Source{},
+				Name:       "NodeNotReady",
+				Type:       sw.Type_Normal,
+				Layer:      k8sLayerName,
+				Parameters: map[string]string{"namespace": "default", "kind": "Node", "count": "3"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.template.Init(); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			swEvent := &sw.Event{Source: &sw.Source{}, Name: kEvent.Reason, Type: sw.Type_Normal, Layer: k8sLayerName}
+			<-tt.template.render(context.Background(), swEvent, kEvent)
+
+			if !reflect.DeepEqual(swEvent, tt.want) {
+				t.Errorf("render() = %+v, want %+v", swEvent, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventTemplate_parse(t *testing.T) {
+	template := &EventTemplate{
+		Types:      map[string]string{"Warning": "Critical"},
+		Parameters: map[string]string{"node": "{{ .Node.Name"},
+	}
+
+	var fields []string
+	for _, err := range template.parse() {
+		fields = append(fields, err.(*configs.FieldError).Field)
+	}
+	if want := []string{"parameters.node", "types.Warning"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("parse() fields = %v, want %v", fields, want)
+	}
+}
+
+func TestSkyWalking_render_timeout(t *testing.T) {
+	renderTimeout = 20 * time.Millisecond
+	rendered := make(chan struct{})
+	funcMap["slow"] = func() string {
+		defer close(rendered)
+		time.Sleep(200 * time.Millisecond)
+		return "slow"
+	}
+	defer func() {
+		renderTimeout = time.Minute
+		delete(funcMap, "slow")
+	}()
+
+	template := &EventTemplate{Message: "{{ slow }}"}
+	if err := template.Init(); err != nil {
+		t.Fatal(err)
+	}
+	server, conn := startEventServer(t)
+	deadLetters := &recordedDeadLetters{}
+	exporter := &SkyWalking{
+		config:        SkyWalkingConfig{BatchSize: 1, Template: template},
+		client:        sw.NewEventServiceClient(conn),
+		batchInterval: time.Hour,
+		callTimeout:   time.Second,
+	}
+	exporter.SetDeadLetters(deadLetters)
+
+	events := make(chan *k8s.Event)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		exporter.Export(context.Background(), events)
+	}()
+	events <- &k8s.Event{Event: &corev1.Event{ObjectMeta: metav1.ObjectMeta{UID: "1"}, Message: "original"}}
+	close(events)
+	<-done
+	// The rendering goroutine finishes writing the abandoned event without blocking or racing with the exporter.
+	<-rendered
+
+	if got := server.received(); got != 0 {
+		t.Errorf("got %v batches, want the event that is not rendered in time not sent", got)
+	}
+	if len(deadLetters.letters) != 1 || deadLetters.letters[0].Rendered != nil || !strings.Contains(deadLetters.letters[0].Reason, "not rendered") {
+		t.Errorf("got dead letters %+v, want the event that is not rendered in time", deadLetters.letters)
+	}
+}
@@ -0,0 +1,143 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package exporter
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/resolver"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
+)
+
+// addressesScheme is the scheme of the target that is resolved by addressesResolverBuilder.
+const addressesScheme = "skywalking"
+
+// defaultResolveInterval is the default interval to re-resolve the addresses of the OAP servers.
+const defaultResolveInterval = 30 * time.Second
+
+// addressesResolverBuilder builds the resolver that resolves all the configured addresses of the OAP
+// servers, a DNS name, like the one of a headless Service, is resolved to all its IP addresses.
+type addressesResolverBuilder struct {
+	addresses  []string
+	interval   time.Duration
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+}
+
+func (b *addressesResolverBuilder) Build(_ resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &addressesResolver{
+		builder: b,
+		cc:      cc,
+		cancel:  cancel,
+		resolve: make(chan struct{}, 1),
+	}
+
+	r.wg.Add(1)
+	go r.watch(ctx)
+
+	return r, nil
+}
+
+func (b *addressesResolverBuilder) Scheme() string {
+	return addressesScheme
+}
+
+type addressesResolver struct {
+	builder *addressesResolverBuilder
+	cc      resolver.ClientConn
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	resolve chan struct{}
+}
+
+// ResolveNow is called by gRPC when a connection fails, to re-resolve the addresses.
+func (r *addressesResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.resolve <- struct{}{}:
+	default:
+	}
+}
+
+func (r *addressesResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+// watch resolves the addresses at the interval, or when it's requested, until the context is done.
+func (r *addressesResolver) watch(ctx context.Context) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.builder.interval)
+	defer ticker.Stop()
+
+	for {
+		addresses, err := r.builder.resolveAddresses(ctx)
+		if len(addresses) > 0 {
+			if err != nil {
+				logger.Log.Warnf("failed to resolve some addresses of SkyWalking servers. %+v", err)
+			}
+			if err := r.cc.UpdateState(resolver.State{Addresses: addresses}); err != nil {
+				logger.Log.Debugf("failed to update the addresses of SkyWalking servers. %+v", err)
+			}
+		} else if err != nil {
+			r.cc.ReportError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.resolve:
+		}
+	}
+}
+
+// resolveAddresses resolves all the addresses, the addresses that fail to be resolved are skipped,
+// and the last error is returned.
+func (b *addressesResolverBuilder) resolveAddresses(ctx context.Context) (addresses []resolver.Address, err error) {
+	for _, address := range b.addresses {
+		host, port, splitErr := net.SplitHostPort(address)
+		if splitErr != nil {
+			err = splitErr
+			continue
+		}
+		if net.ParseIP(host) != nil {
+			addresses = append(addresses, resolver.Address{Addr: address, ServerName: host})
+			continue
+		}
+
+		lookupCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		ips, lookupErr := b.lookupHost(lookupCtx, host)
+		cancel()
+		if lookupErr != nil {
+			err = lookupErr
+			continue
+		}
+		for _, ip := range ips {
+			// The host name is kept to verify the certificates of the servers.
+			addresses = append(addresses, resolver.Address{Addr: net.JoinHostPort(ip, port), ServerName: host})
+		}
+	}
+	return addresses, err
+}
@@ -0,0 +1,55 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package exporter
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/resolver"
+)
+
+func TestAddressesResolverBuilder_resolveAddresses(t *testing.T) {
+	builder := &addressesResolverBuilder{
+		addresses: []string{"10.0.0.1:11800", "oap.skywalking:11800", "unknown.skywalking:11800", "[fd00::1]:11800"},
+		lookupHost: func(_ context.Context, host string) ([]string, error) {
+			if host == "oap.skywalking" {
+				return []string{"10.0.1.1", "10.0.1.2"}, nil
+			}
+			return nil, errors.New("no such host")
+		},
+	}
+
+	addresses, err := builder.resolveAddresses(context.Background())
+	if err == nil {
+		t.Errorf("resolveAddresses() should return the error of unknown.skywalking")
+	}
+	want := []resolver.Address{
+		{Addr: "10.0.0.1:11800", ServerName: "10.0.0.1"},
+		{Addr: "10.0.1.1:11800", ServerName: "oap.skywalking"},
+		{Addr: "10.0.1.2:11800", ServerName: "oap.skywalking"},
+		{Addr: "[fd00::1]:11800", ServerName: "fd00::1"},
+	}
+	if !reflect.DeepEqual(addresses, want) {
+		t.Errorf("resolveAddresses() = %v, want %v", addresses, want)
+	}
+}
@@ -0,0 +1,230 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/configs"
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
+)
+
+// Defaults of the retry policy.
+var (
+	defaultMaxAttempts       = 5
+	defaultInitialBackoff    = 500 * time.Millisecond
+	defaultMaxBackoff        = 30 * time.Second
+	defaultMultiplier        = 2.0
+	defaultJitter            = 0.2
+	defaultRetryableCodes    = []string{codes.Unavailable.String()}
+	defaultRetryableStatuses = []int{429, 502, 503, 504}
+)
+
+// grpcCodes are the gRPC codes keyed by their names, like "Unavailable".
+var grpcCodes = func() map[string]codes.Code {
+	m := map[string]codes.Code{}
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		m[c.String()] = c
+	}
+	return m
+}()
+
+// RetryConfig configures how an exporter retries the events that fail to be exported.
+type RetryConfig struct {
+	// MaxAttempts is the maximum number of the attempts, including the first one, 5 by default, so that
+	// an exporter never blocks forever on a backend that stays down.
+	MaxAttempts int `mapstructure:"maxAttempts"`
+	// InitialBackoff is the backoff before the first retry, like "500ms", the backoff is multiplied by
+	// Multiplier after every retry, up to MaxBackoff, and randomized by ±Jitter, like 0.2.
+	InitialBackoff string  `mapstructure:"initialBackoff"`
+	MaxBackoff     string  `mapstructure:"maxBackoff"`
+	Multiplier     float64 `mapstructure:"multiplier"`
+	// Jitter is a pointer to tell the absent jitter, which takes the default, from 0, which disables jittering.
+	Jitter *float64 `mapstructure:"jitter"`
+	// RetryableCodes are the gRPC codes of the errors to retry, like "Unavailable".
+	RetryableCodes []string `mapstructure:"retryableCodes"`
+	// RetryableStatuses are the HTTP status codes of the errors to retry, like 503, for the exporters
+	// that send the events over HTTP and return HTTPStatusError.
+	RetryableStatuses []int `mapstructure:"retryableStatuses"`
+}
+
+// RetryPolicy retries the operations that fail with the retryable errors, with exponential backoff and jitter.
+// A nil RetryPolicy doesn't retry.
+type RetryPolicy struct {
+	maxAttempts       int
+	initialBackoff    time.Duration
+	maxBackoff        time.Duration
+	multiplier        float64
+	jitter            float64
+	retryableCodes    map[codes.Code]bool
+	retryableStatuses map[int]bool
+}
+
+// HTTPStatusError is the error of an HTTP response, which is retried if its status code is retryable.
+type HTTPStatusError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("HTTP status %v: %v", e.StatusCode, e.Err)
+}
+
+func (e *HTTPStatusError) Unwrap() error {
+	return e.Err
+}
+
+// Policy returns the retry policy of the configurations, the absent configurations take the defaults.
+func (config *RetryConfig) Policy() (*RetryPolicy, error) {
+	if config == nil {
+		config = &RetryConfig{}
+	}
+	if errs := config.validate(); len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	policy := &RetryPolicy{
+		maxAttempts:       config.MaxAttempts,
+		multiplier:        config.Multiplier,
+		jitter:            defaultJitter,
+		retryableCodes:    map[codes.Code]bool{},
+		retryableStatuses: map[int]bool{},
+	}
+	policy.initialBackoff, _ = parseDuration(config.InitialBackoff, defaultInitialBackoff)
+	policy.maxBackoff, _ = parseDuration(config.MaxBackoff, defaultMaxBackoff)
+	if policy.maxAttempts == 0 {
+		policy.maxAttempts = defaultMaxAttempts
+	}
+	if policy.multiplier == 0 {
+		policy.multiplier = defaultMultiplier
+	}
+	if config.Jitter != nil {
+		policy.jitter = *config.Jitter
+	}
+
+	retryableCodes := config.RetryableCodes
+	if len(retryableCodes) == 0 {
+		retryableCodes = defaultRetryableCodes
+	}
+	for _, name := range retryableCodes {
+		policy.retryableCodes[grpcCodes[name]] = true
+	}
+	retryableStatuses := config.RetryableStatuses
+	if len(retryableStatuses) == 0 {
+		retryableStatuses = defaultRetryableStatuses
+	}
+	for _, code := range retryableStatuses {
+		policy.retryableStatuses[code] = true
+	}
+
+	return policy, nil
+}
+
+// validate validates the retry configurations.
+func (config *RetryConfig) validate() (errs []error) {
+	if config == nil {
+		return nil
+	}
+
+	if config.MaxAttempts < 0 {
+		errs = append(errs, &configs.FieldError{Field: "maxAttempts", Err: errors.New("maxAttempts cannot be negative")})
+	}
+	durations := map[string]string{"initialBackoff": config.InitialBackoff, "maxBackoff": config.MaxBackoff}
+	for _, field := range sortedKeys(durations) {
+		if _, err := parseDuration(durations[field], 0); err != nil {
+			errs = append(errs, &configs.FieldError{Field: field, Err: err})
+		}
+	}
+	if config.Multiplier != 0 && config.Multiplier < 1 {
+		errs = append(errs, &configs.FieldError{Field: "multiplier", Err: errors.New("multiplier cannot be less than 1")})
+	}
+	if config.Jitter != nil && (*config.Jitter < 0 || *config.Jitter > 1) {
+		errs = append(errs, &configs.FieldError{Field: "jitter", Err: errors.New("jitter must be between 0 and 1")})
+	}
+	for i, name := range config.RetryableCodes {
+		if _, ok := grpcCodes[name]; !ok {
+			errs = append(errs, &configs.FieldError{Field: fmt.Sprintf("retryableCodes[%v]", i), Err: fmt.Errorf("%q is not a gRPC code, like Unavailable", name)})
+		}
+	}
+	for i, code := range config.RetryableStatuses {
+		if code < 100 || code > 599 {
+			errs = append(errs, &configs.FieldError{Field: fmt.Sprintf("retryableStatuses[%v]", i), Err: fmt.Errorf("%v is not an HTTP status code", code)})
+		}
+	}
+
+	return errs
+}
+
+// Retryable reports whether the error is retryable by its gRPC code or HTTP status code.
+func (policy *RetryPolicy) Retryable(err error) bool {
+	if policy == nil || err == nil {
+		return false
+	}
+
+	var httpErr *HTTPStatusError
+	if errors.As(err, &httpErr) {
+		return policy.retryableStatuses[httpErr.StatusCode]
+	}
+	if s, ok := status.FromError(err); ok {
+		return policy.retryableCodes[s.Code()]
+	}
+	return false
+}
+
+// Do calls op until it succeeds, fails with an error that is not retryable, the attempts are exhausted,
+// or the context is done, the last error is returned.
+func (policy *RetryPolicy) Do(ctx context.Context, op func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || !policy.Retryable(err) || ctx.Err() != nil {
+			return err
+		}
+		if attempt >= policy.maxAttempts {
+			return fmt.Errorf("gave up after %v attempts: %w", attempt, err)
+		}
+
+		backoff := policy.backoff(attempt)
+		logger.Log.Warnf("attempt %v failed, retrying in %v. %+v", attempt, backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// backoff returns the backoff after the given attempt.
+func (policy *RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := float64(policy.initialBackoff) * math.Pow(policy.multiplier, float64(attempt-1))
+	if backoff > float64(policy.maxBackoff) {
+		backoff = float64(policy.maxBackoff)
+	}
+	backoff *= 1 + policy.jitter*(rand.Float64()*2-1) //nolint:gosec // the jitter doesn't need to be secure.
+	return time.Duration(backoff)
+}
@@ -0,0 +1,202 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/configs"
+)
+
+func TestRetryPolicy_Retryable(t *testing.T) {
+	defaults, err := (*RetryConfig)(nil).Policy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom, err := (&RetryConfig{RetryableCodes: []string{"ResourceExhausted"}, RetryableStatuses: []int{500}}).Policy()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		policy *RetryPolicy
+		err    error
+		want   bool
+	}{
+		{name: "default gRPC code", policy: defaults, err: status.Error(codes.Unavailable, "down"), want: true},
+		{name: "default non-retryable gRPC code", policy: defaults, err: status.Error(codes.InvalidArgument, "invalid"), want: false},
+		{name: "default HTTP status", policy: defaults, err: &HTTPStatusError{StatusCode: 503, Err: errors.New("down")}, want: true},
+		{name: "wrapped HTTP status", policy: defaults, err: fmt.Errorf("wrapped: %w", &HTTPStatusError{StatusCode: 429}), want: true},
+		{name: "default non-retryable HTTP status", policy: defaults, err: &HTTPStatusError{StatusCode: 400}, want: false},
+		{name: "custom HTTP status", policy: custom, err: &HTTPStatusError{StatusCode: 500}, want: true},
+		{name: "custom non-retryable HTTP status", policy: custom, err: &HTTPStatusError{StatusCode: 503}, want: false},
+		{name: "custom gRPC code", policy: custom, err: status.Error(codes.ResourceExhausted, "busy"), want: true},
+		{name: "custom non-retryable gRPC code", policy: custom, err: status.Error(codes.Unavailable, "down"), want: false},
+		{name: "other errors", policy: defaults, err: errors.New("unknown"), want: false},
+		{name: "nil policy", policy: nil, err: status.Error(codes.Unavailable, "down"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Retryable(tt.err); got != tt.want {
+				t.Errorf("Retryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	jitter := 0.1
+	policy, err := (&RetryConfig{InitialBackoff: "100ms", MaxBackoff: "1s", Multiplier: 3, Jitter: &jitter}).Policy()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for attempt, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 300 * time.Millisecond,
+		3: 900 * time.Millisecond,
+		4: time.Second,
+		9: time.Second,
+	} {
+		backoff := policy.backoff(attempt)
+		if backoff < want*9/10 || backoff > want*11/10 {
+			t.Errorf("backoff(%v) = %v, want %v±10%%", attempt, backoff, want)
+		}
+	}
+}
+
+func TestRetryConfig_Policy_jitter(t *testing.T) {
+	zero, half := 0.0, 0.5
+
+	tests := []struct {
+		name   string
+		config *RetryConfig
+		want   float64
+	}{
+		{name: "absent", config: &RetryConfig{}, want: defaultJitter},
+		{name: "zero disables jittering", config: &RetryConfig{Jitter: &zero}, want: 0},
+		{name: "custom", config: &RetryConfig{Jitter: &half}, want: 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := tt.config.Policy()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if policy.jitter != tt.want {
+				t.Errorf("jitter = %v, want %v", policy.jitter, tt.want)
+			}
+		})
+	}
+
+	// The decoded "jitter: 0" disables jittering, so the backoff is exact.
+	config := &RetryConfig{}
+	if err := decodeConfig(configs.ExporterConfig{"jitter": 0, "initialBackoff": "100ms"}, config, true); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := config.Policy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if backoff := policy.backoff(1); backoff != 100*time.Millisecond {
+			t.Fatalf("backoff(1) = %v, want exactly 100ms", backoff)
+		}
+	}
+}
+
+func TestRetryPolicy_Do_defaultAttempts(t *testing.T) {
+	policy, err := (&RetryConfig{InitialBackoff: "1ms", MaxBackoff: "1ms"}).Policy()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attempts := 0
+	err = policy.Do(context.Background(), func() error {
+		attempts++
+		return status.Error(codes.Unavailable, "down")
+	})
+	if err == nil || attempts != defaultMaxAttempts {
+		t.Errorf("Do() attempts = %v, error = %v, want %v attempts and an error", attempts, err, defaultMaxAttempts)
+	}
+}
+
+func TestRetryPolicy_Do(t *testing.T) {
+	policy, err := (&RetryConfig{MaxAttempts: 3, InitialBackoff: "1ms", MaxBackoff: "1ms"}).Policy()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		errs         []error
+		wantAttempts int
+		wantErr      bool
+	}{
+		{name: "succeeded", errs: []error{nil}, wantAttempts: 1},
+		{name: "succeeded after retries", errs: []error{status.Error(codes.Unavailable, ""), status.Error(codes.Unavailable, ""), nil}, wantAttempts: 3},
+		{name: "not retryable", errs: []error{status.Error(codes.InvalidArgument, "")}, wantAttempts: 1, wantErr: true},
+		{name: "attempts exhausted", errs: []error{
+			status.Error(codes.Unavailable, ""), status.Error(codes.Unavailable, ""), status.Error(codes.Unavailable, ""), nil,
+		}, wantAttempts: 3, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := policy.Do(context.Background(), func() error {
+				attempts++
+				return tt.errs[attempts-1]
+			})
+			if attempts != tt.wantAttempts || (err != nil) != tt.wantErr {
+				t.Errorf("Do() attempts = %v, error = %v, want %v attempts and error %v", attempts, err, tt.wantAttempts, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryConfig_validate(t *testing.T) {
+	jitter := 2.0
+	config := &RetryConfig{
+		MaxAttempts:       -1,
+		InitialBackoff:    "1",
+		Multiplier:        0.5,
+		Jitter:            &jitter,
+		RetryableCodes:    []string{"Unavailable", "Down"},
+		RetryableStatuses: []int{503, 50},
+	}
+
+	var fields []string
+	for _, err := range config.validate() {
+		fields = append(fields, err.(*configs.FieldError).Field)
+	}
+	want := []string{"maxAttempts", "initialBackoff", "multiplier", "jitter", "retryableCodes[1]", "retryableStatuses[1]"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("validate() fields = %v, want %v", fields, want)
+	}
+}
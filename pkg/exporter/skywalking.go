@@ -21,100 +21,197 @@ package exporter
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding/gzip"
+	// Registers the client side health checking of the OAP servers.
+	_ "google.golang.org/grpc/health"
+	grpckeepalive "google.golang.org/grpc/keepalive"
 	k8score "k8s.io/api/core/v1"
 	sw "skywalking.apache.org/repo/goapi/collect/event/v3"
 
 	"github.com/apache/skywalking-kubernetes-event-exporter/configs"
 	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/metrics"
+	"github.com/apache/skywalking-kubernetes-event-exporter/pkg/k8s"
 )
 
 // k8sLayerName is the name of the layer that represents the k8s event,
 // which is defined at https://github.com/apache/skywalking/blob/master/oap-server/server-core/src/main/java/org/apache/skywalking/oap/server/core/analysis/Layer.java
 const k8sLayerName = "K8S"
 
+// The balancers of the events among the OAP servers, which are mapped to the gRPC load balancing policies.
+var balancers = map[string]string{
+	"failover":   "pick_first",
+	"roundRobin": "round_robin",
+}
+
+// fileReloadInterval is the interval to check the token and certificate files for changes.
+var fileReloadInterval = 10 * time.Second
+
+// Defaults of the batching and the calls of SkyWalking Exporter.
+const (
+	defaultBatchSize     = 100
+	defaultBatchInterval = time.Second
+	defaultCallTimeout   = 10 * time.Second
+)
+
 // SkyWalking Exporter exports the events into Apache SkyWalking OAP server.
 type SkyWalking struct {
+	deadLettering
+	redacting
+
 	config SkyWalkingConfig
 	client sw.EventServiceClient
+
+	batchInterval time.Duration
+	callTimeout   time.Duration
+	callOptions   []grpc.CallOption
+	authenticator *authenticator
+	retry         *RetryPolicy
 }
 
 type SkyWalkingConfig struct {
-	Address            string         `mapstructure:"address"`
+	Address string `mapstructure:"address"`
+	// Addresses are the addresses of the OAP servers, they take precedence over Address, and a DNS name,
+	// like the one of a headless Service, is resolved to all its IP addresses every ResolveInterval.
+	Addresses       []string `mapstructure:"addresses"`
+	ResolveInterval string   `mapstructure:"resolveInterval"`
+	// Balancer is how the events are balanced among the OAP servers, "failover" sends to the first
+	// available one, and "roundRobin" sends to the available ones in turn.
+	Balancer string `mapstructure:"balancer"`
+	// HealthCheck enables the gRPC health checking of the OAP servers with the "roundRobin" balancer,
+	// the unhealthy ones are ejected until they are healthy again.
+	HealthCheck bool `mapstructure:"healthCheck"`
+
 	Template           *EventTemplate `mapstructure:"template"`
 	EnableTLS          bool           `mapstructure:"enableTLS"`
 	ClientCertPath     string         `mapstructure:"clientCertPath"`
 	ClientKeyPath      string         `mapstructure:"clientKeyPath"`
 	TrustedCertPath    string         `mapstructure:"trustedCertPath"`
 	InsecureSkipVerify bool           `mapstructure:"insecureSkipVerify"`
+	// MinTLSVersion is the minimum TLS version, "1.2" or "1.3", it defaults to "1.3" with the client
+	// certificates, and "1.2" otherwise. ServerName overrides the server name to verify the certificates.
+	MinTLSVersion string `mapstructure:"minTLSVersion"`
+	ServerName    string `mapstructure:"serverName"`
+	ClusterPrefix bool   `mapstructure:"clusterPrefix"`
+	// BatchSize is the maximum number of events sent in one call, and BatchInterval is the
+	// maximum time to wait for a batch to be full, like "1s".
+	BatchSize     int    `mapstructure:"batchSize"`
+	BatchInterval string `mapstructure:"batchInterval"`
+	// Compression is the compressor of the calls, only "gzip" is supported, empty means no compression.
+	Compression string `mapstructure:"compression"`
+	// MaxMessageSize is the maximum size in bytes of a message sent to the OAP server, 0 means gRPC's default.
+	MaxMessageSize int `mapstructure:"maxMessageSize"`
+	// Timeout is the timeout of every call to send a batch, like "10s".
+	Timeout   string           `mapstructure:"timeout"`
+	Keepalive *KeepaliveConfig `mapstructure:"keepalive"`
+	// Workers is the number of the events rendered and exported concurrently, the events of
+	// the same involved object are always exported in order.
+	Workers int `mapstructure:"workers"`
+	// Authentication is the token sent to the OAP server on every call, empty means no authentication.
+	Authentication *AuthenticationConfig `mapstructure:"authentication"`
+	// Retry is the retry policy of the batches that fail to be sent.
+	Retry *RetryConfig `mapstructure:"retry"`
+}
+
+// KeepaliveConfig configures the keepalive pings of the connection to the OAP server.
+type KeepaliveConfig struct {
+	// Time is the interval to ping the OAP server if there is no activity, like "30s".
+	Time string `mapstructure:"time"`
+	// Timeout is how long to wait for the ping ack before closing the connection, like "10s".
+	Timeout             string `mapstructure:"timeout"`
+	PermitWithoutStream bool   `mapstructure:"permitWithoutStream"`
 }
 
 func init() {
 	s := &SkyWalking{}
-	RegisterExporter(s.Name(), s)
+	RegisterExporter(s.Name(), func() Exporter {
+		return &SkyWalking{}
+	})
 }
 
-func (exporter *SkyWalking) Init(ctx context.Context) error {
+func (exporter *SkyWalking) Init(ctx context.Context, c configs.ExporterConfig) error {
 	config := SkyWalkingConfig{}
 
-	if c := configs.GlobalConfig.Exporters[exporter.Name()]; c == nil {
+	if c == nil {
 		return fmt.Errorf("configs of %+v exporter cannot be empty", exporter.Name())
-	} else if marshal, err := json.Marshal(c); err != nil {
-		return err
-	} else if err := json.Unmarshal(marshal, &config); err != nil {
+	} else if err := decodeConfig(c, &config, false); err != nil {
 		return err
 	}
 
 	if err := config.Template.Init(); err != nil {
 		return err
 	}
+	if errs := config.validateCalls(); len(errs) > 0 {
+		return errs[0]
+	}
+	retry, err := config.Retry.Policy()
+	if err != nil {
+		return err
+	}
+	exporter.retry = retry
+	if config.Authentication != nil {
+		auth, err := newAuthenticator(ctx, config.Authentication)
+		if err != nil {
+			return err
+		}
+		exporter.authenticator = auth
+	}
 
-	var dialOption grpc.DialOption
+	dialOption := grpc.WithInsecure()
 	if config.EnableTLS {
-		if isFileExisted(config.ClientCertPath) && isFileExisted(config.ClientKeyPath) {
-			clientCert, err := tls.LoadX509KeyPair(config.ClientCertPath, config.ClientKeyPath)
-			if err != nil {
-				return err
-			}
-			trustedCert, err := os.ReadFile(config.TrustedCertPath)
-			if err != nil {
-				return err
-			}
-			certPool := x509.NewCertPool()
-			certPool.AppendCertsFromPEM(trustedCert)
-
-			tlsConfig := &tls.Config{
-				Certificates: []tls.Certificate{clientCert},
-				RootCAs:      certPool,
-				MinVersion:   tls.VersionTLS13,
-				MaxVersion:   tls.VersionTLS13,
-			}
-			tlsConfig.InsecureSkipVerify = config.InsecureSkipVerify
-			dialOption = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
-		} else {
-			cred, _ := credentials.NewClientTLSFromFile(config.TrustedCertPath, "")
-			dialOption = grpc.WithTransportCredentials(cred)
+		creds, err := newTLSCredentials(ctx, &config)
+		if err != nil {
+			return err
 		}
-	} else {
-		dialOption = grpc.WithInsecure()
+		dialOption = grpc.WithTransportCredentials(creds)
+	}
+
+	dialOptions := []grpc.DialOption{dialOption}
+	if keepalive := config.Keepalive; keepalive != nil {
+		params := grpckeepalive.ClientParameters{PermitWithoutStream: keepalive.PermitWithoutStream}
+		params.Time, _ = parseDuration(keepalive.Time, 0)
+		params.Timeout, _ = parseDuration(keepalive.Timeout, 0)
+		dialOptions = append(dialOptions, grpc.WithKeepaliveParams(params))
 	}
 
-	conn, err := grpc.Dial(config.Address, dialOption)
+	resolveInterval, _ := parseDuration(config.ResolveInterval, defaultResolveInterval)
+	dialOptions = append(dialOptions,
+		grpc.WithResolvers(&addressesResolverBuilder{
+			addresses:  config.addresses(),
+			interval:   resolveInterval,
+			lookupHost: net.DefaultResolver.LookupHost,
+		}),
+		grpc.WithDefaultServiceConfig(config.serviceConfig()),
+	)
+
+	conn, err := grpc.Dial(addressesScheme+":///"+exporter.Name(), dialOptions...)
 	if err != nil {
 		return err
 	}
 
 	exporter.config = config
 	exporter.client = sw.NewEventServiceClient(conn)
+	exporter.batchInterval, _ = parseDuration(config.BatchInterval, defaultBatchInterval)
+	exporter.callTimeout, _ = parseDuration(config.Timeout, defaultCallTimeout)
+	if exporter.config.BatchSize <= 0 {
+		exporter.config.BatchSize = defaultBatchSize
+	}
+	if config.Compression != "" {
+		exporter.callOptions = append(exporter.callOptions, grpc.UseCompressor(config.Compression))
+	}
+	if config.MaxMessageSize > 0 {
+		exporter.callOptions = append(exporter.callOptions, grpc.MaxCallSendMsgSize(config.MaxMessageSize))
+	}
 
 	go func() {
 		<-ctx.Done()
@@ -127,6 +224,126 @@ func (exporter *SkyWalking) Init(ctx context.Context) error {
 	return nil
 }
 
+func (exporter *SkyWalking) Validate(c configs.ExporterConfig) []error {
+	config := SkyWalkingConfig{}
+
+	errs, ok := decodeConfigForValidation(c, &config)
+	if !ok {
+		return errs
+	}
+
+	errs = append(errs, prefixFieldErrors("template", config.Template.parse())...)
+	errs = append(errs, config.validateCalls()...)
+	errs = append(errs, validateWorkers(config.Workers)...)
+	errs = append(errs, prefixFieldErrors("retry", config.Retry.validate())...)
+	if config.Authentication != nil {
+		errs = append(errs, config.Authentication.validate()...)
+	}
+
+	if len(config.Addresses) == 0 {
+		if config.Address == "" {
+			errs = append(errs, &configs.FieldError{Field: "address", Err: errors.New("address cannot be empty")})
+		} else if _, _, err := net.SplitHostPort(config.Address); err != nil {
+			errs = append(errs, &configs.FieldError{Field: "address", Err: err})
+		}
+	}
+	for i, address := range config.Addresses {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			errs = append(errs, &configs.FieldError{Field: fmt.Sprintf("addresses[%v]", i), Err: err})
+		}
+	}
+	if _, ok := balancers[config.Balancer]; config.Balancer != "" && !ok {
+		errs = append(errs, &configs.FieldError{Field: "balancer", Err: fmt.Errorf("balancer %q is not supported, it must be one of %v", config.Balancer, sortedKeys(balancers))})
+	}
+
+	if config.EnableTLS {
+		if (config.ClientCertPath == "") != (config.ClientKeyPath == "") {
+			errs = append(errs, &configs.FieldError{Field: "clientCertPath", Err: errors.New("clientCertPath and clientKeyPath must be specified together")})
+		}
+		for field, path := range map[string]string{
+			"clientCertPath":  config.ClientCertPath,
+			"clientKeyPath":   config.ClientKeyPath,
+			"trustedCertPath": config.TrustedCertPath,
+		} {
+			if path != "" && !isFileExisted(path) {
+				errs = append(errs, &configs.FieldError{Field: field, Err: fmt.Errorf("file %v does not exist", path)})
+			}
+		}
+		if _, ok := tlsVersions[config.MinTLSVersion]; config.MinTLSVersion != "" && !ok {
+			errs = append(errs, &configs.FieldError{Field: "minTLSVersion", Err: fmt.Errorf("TLS version %q is not supported, it must be \"1.2\" or \"1.3\"", config.MinTLSVersion)})
+		}
+	}
+
+	return errs
+}
+
+// validateCalls validates the configurations of the batching and the calls.
+func (config *SkyWalkingConfig) validateCalls() (errs []error) {
+	durations := map[string]string{
+		"batchInterval":   config.BatchInterval,
+		"resolveInterval": config.ResolveInterval,
+		"timeout":         config.Timeout,
+	}
+	if config.Keepalive != nil {
+		durations["keepalive.time"] = config.Keepalive.Time
+		durations["keepalive.timeout"] = config.Keepalive.Timeout
+	}
+	for _, field := range sortedKeys(durations) {
+		if _, err := parseDuration(durations[field], 0); err != nil {
+			errs = append(errs, &configs.FieldError{Field: field, Err: err})
+		}
+	}
+
+	if config.BatchSize < 0 {
+		errs = append(errs, &configs.FieldError{Field: "batchSize", Err: errors.New("batchSize cannot be negative")})
+	}
+	if config.MaxMessageSize < 0 {
+		errs = append(errs, &configs.FieldError{Field: "maxMessageSize", Err: errors.New("maxMessageSize cannot be negative")})
+	}
+	if config.Compression != "" && config.Compression != gzip.Name {
+		errs = append(errs, &configs.FieldError{Field: "compression", Err: fmt.Errorf("compression %q is not supported, it must be %v", config.Compression, gzip.Name)})
+	}
+
+	return errs
+}
+
+// addresses returns the addresses of the OAP servers.
+func (config *SkyWalkingConfig) addresses() []string {
+	if len(config.Addresses) > 0 {
+		return config.Addresses
+	}
+	return []string{config.Address}
+}
+
+// serviceConfig returns the gRPC service config of the balancer and the health checking.
+func (config *SkyWalkingConfig) serviceConfig() string {
+	policy, ok := balancers[config.Balancer]
+	if !ok {
+		policy = balancers["failover"]
+	}
+
+	serviceConfig := fmt.Sprintf(`{"loadBalancingConfig": [{%q: {}}]`, policy)
+	if config.HealthCheck {
+		serviceConfig += `, "healthCheckConfig": {"serviceName": ""}`
+	}
+	return serviceConfig + "}"
+}
+
+// parseDuration parses the duration like "10s", d is returned if s is empty.
+func parseDuration(s string, d time.Duration) (time.Duration, error) {
+	if s == "" {
+		return d, nil
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return d, err
+	}
+	if duration < 0 {
+		return d, fmt.Errorf("duration %v cannot be negative", s)
+	}
+	return duration, nil
+}
+
 // checkTLSFile checks the TLS files.
 func isFileExisted(path string) bool {
 	file, err := os.Open(path)
@@ -141,75 +358,209 @@ func (exporter *SkyWalking) Name() string {
 	return "skywalking"
 }
 
-func (exporter *SkyWalking) Export(ctx context.Context, events chan *k8score.Event) {
+func (exporter *SkyWalking) Export(ctx context.Context, events chan *k8s.Event) {
 	logger.Log.Debugf("exporting events into %+v", exporter.Name())
 
-	stream, err := exporter.client.Collect(ctx)
+	batch := make(chan *renderedEvent)
+	batched := make(chan struct{})
+	go func() {
+		defer close(batched)
+		exporter.batch(ctx, batch)
+	}()
 
-	for err != nil {
-		select {
-		case <-ctx.Done():
-			logger.Log.Debugf("stopping exporter %+v", exporter.Name())
-			if err = stream.CloseSend(); err != nil {
-				logger.Log.Warnf("failed to close stream. %+v", err)
-			}
+	workers := k8s.StartOrderedWorkers(ctx, exporter.config.Workers, func(kEvent *k8s.Event) {
+		swEvent, err := exporter.render(ctx, kEvent)
+		if err != nil {
+			logger.Log.Errorf("failed to render event %v for %v. %+v", kEvent.UID, exporter.Name(), err)
+			exporter.deadLetter(exporter.Name(), kEvent, nil, err)
 			return
-		default:
-			logger.Log.Errorf("failed to connect to SkyWalking server. %+v", err)
-			time.Sleep(3 * time.Second)
-			stream, err = exporter.client.Collect(ctx)
 		}
-	}
+		exporter.export(ctx, batch, kEvent, swEvent)
+	})
 
 	for {
 		select {
 		case <-ctx.Done():
+			metrics.DropEvents(workers.Stop())
+			<-batched
 			logger.Log.Debugf("stopping exporter %+v", exporter.Name())
 			return
-		case kEvent := <-events:
+		case kEvent, ok := <-events:
+			if !ok {
+				metrics.DropEvents(workers.Stop())
+				close(batch)
+				<-batched
+				logger.Log.Debugf("stopping exporter %+v, all events have been exported", exporter.Name())
+				return
+			}
 			if logger.Log.IsLevelEnabled(logrus.DebugLevel) {
 				if bytes, err := json.Marshal(kEvent); err == nil {
 					logger.Log.Debugf("exporting event to %v: %v", exporter.Name(), string(bytes))
 				}
 			}
 
-			t := sw.Type_Normal
-			if kEvent.Type == k8score.EventTypeWarning {
-				t = sw.Type_Error
+			if !workers.Dispatch(ctx, kEvent) {
+				metrics.DropEvents(1)
 			}
-			swEvent := &sw.Event{
-				Uuid:      string(kEvent.UID),
-				Source:    &sw.Source{},
-				Name:      kEvent.Reason,
-				Type:      t,
-				Message:   kEvent.Message,
-				StartTime: kEvent.FirstTimestamp.UnixNano() / 1000000,
-				EndTime:   kEvent.LastTimestamp.UnixNano() / 1000000,
-				Layer:     k8sLayerName,
+		}
+	}
+}
+
+// render renders the SkyWalking event of the Kubernetes event, it fails if the template is not rendered in time.
+func (exporter *SkyWalking) render(ctx context.Context, kEvent *k8s.Event) (*sw.Event, error) {
+	t := sw.Type_Normal
+	if kEvent.Type == k8score.EventTypeWarning {
+		t = sw.Type_Error
+	}
+	swEvent := &sw.Event{
+		Uuid:      string(kEvent.UID),
+		Source:    &sw.Source{},
+		Name:      kEvent.Reason,
+		Type:      t,
+		Message:   kEvent.Message,
+		StartTime: kEvent.FirstTimestamp.UnixNano() / 1000000,
+		EndTime:   kEvent.LastTimestamp.UnixNano() / 1000000,
+		Layer:     k8sLayerName,
+	}
+	if exporter.config.Template == nil {
+		return swEvent, nil
+	}
+
+	renderCtx, cancel := context.WithTimeout(ctx, renderTimeout)
+	defer cancel()
+
+	select {
+	case <-exporter.config.Template.render(renderCtx, swEvent, kEvent):
+		logger.Log.Debugf("done: rendered event is: %+v", swEvent)
+	case <-renderCtx.Done():
+		return nil, fmt.Errorf("event is not rendered: %w", renderCtx.Err())
+	}
+	exporter.redact(swEvent)
+
+	return swEvent, nil
+}
+
+// renderedEvent is the event rendered from the Kubernetes event, which is kept for the dead letters.
+type renderedEvent struct {
+	kEvent  *k8s.Event
+	swEvent *sw.Event
+}
+
+func (exporter *SkyWalking) export(ctx context.Context, batch chan<- *renderedEvent, kEvent *k8s.Event, swEvent *sw.Event) {
+	if exporter.config.ClusterPrefix && kEvent.Cluster != "" && swEvent.Source.Service != "" {
+		swEvent.Source.Service = kEvent.Cluster + "::" + swEvent.Source.Service
+	}
+
+	select {
+	case batch <- &renderedEvent{kEvent: kEvent, swEvent: swEvent}:
+	case <-ctx.Done():
+		metrics.DropEvents(1)
+	}
+}
+
+// batch collects the events into batches, and sends a batch when it's full or the batch interval
+// elapses since its first event, the last batch is sent when the channel is closed.
+func (exporter *SkyWalking) batch(ctx context.Context, events <-chan *renderedEvent) {
+	var batch []*renderedEvent
+	var flush <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			for _, event := range batch {
+				exporter.deadLetter(exporter.Name(), event.kEvent, event.swEvent, ctx.Err())
+			}
+			return
+		case event, ok := <-events:
+			if !ok {
+				exporter.flush(ctx, batch)
+				return
 			}
-			if exporter.config.Template != nil {
-				go func() {
-					renderCtx, cancel := context.WithTimeout(ctx, time.Minute)
-					done := exporter.config.Template.render(renderCtx, swEvent, kEvent)
-					select {
-					case <-done:
-						logger.Log.Debugf("done: rendered event is: %+v", swEvent)
-						exporter.export(stream, swEvent)
-					case <-renderCtx.Done():
-						logger.Log.Debugf("canceled: rendered event is: %+v", swEvent)
-						exporter.export(stream, swEvent)
-					}
-					cancel()
-				}()
-			} else {
-				exporter.export(stream, swEvent)
+			if len(batch) == 0 {
+				flush = time.After(exporter.batchInterval)
 			}
+			batch = append(batch, event)
+			if len(batch) < exporter.config.BatchSize {
+				continue
+			}
+		case <-flush:
 		}
+
+		exporter.flush(ctx, batch)
+		batch, flush = nil, nil
 	}
 }
 
-func (exporter *SkyWalking) export(stream sw.EventService_CollectClient, swEvent *sw.Event) {
-	if err := stream.Send(swEvent); err != nil {
-		logger.Log.Errorf("failed to send event to %+v. %+v", exporter.Name(), err)
+// flush sends the batch, and sends its events to the dead letters if the batch fails to be sent.
+func (exporter *SkyWalking) flush(ctx context.Context, batch []*renderedEvent) {
+	swEvents := make([]*sw.Event, 0, len(batch))
+	for _, event := range batch {
+		swEvents = append(swEvents, event.swEvent)
+	}
+
+	if err := exporter.send(ctx, swEvents); err != nil {
+		for _, event := range batch {
+			exporter.deadLetter(exporter.Name(), event.kEvent, event.swEvent, err)
+		}
+	}
+}
+
+// send sends the batch of events in one call, it's retried with the retry policy,
+// and the error is returned if the batch fails to be sent eventually.
+func (exporter *SkyWalking) send(ctx context.Context, batch []*sw.Event) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	if err := exporter.retry.Do(ctx, func() error {
+		return exporter.collect(ctx, batch)
+	}); err != nil {
+		logger.Log.Errorf("failed to send %v events to %+v. %+v", len(batch), exporter.Name(), err)
+		return err
+	}
+
+	logger.Log.Debugf("sent %v events to %+v", len(batch), exporter.Name())
+	return nil
+}
+
+// Resend sends the rendered events of the dead letters in batches with the retry policy, it stops at the first
+// batch that fails. The events are redacted again, in case they were dead-lettered before the redaction was enabled.
+func (exporter *SkyWalking) Resend(ctx context.Context, events []*sw.Event) (int, error) {
+	for start := 0; start < len(events); start += exporter.config.BatchSize {
+		end := start + exporter.config.BatchSize
+		if end > len(events) {
+			end = len(events)
+		}
+		batch := events[start:end]
+		for _, swEvent := range batch {
+			exporter.redact(swEvent)
+		}
+		if err := exporter.retry.Do(ctx, func() error {
+			return exporter.collect(ctx, batch)
+		}); err != nil {
+			return start, fmt.Errorf("failed to resend events %v to %v: %w", start, end, err)
+		}
+	}
+	return len(events), nil
+}
+
+func (exporter *SkyWalking) collect(ctx context.Context, batch []*sw.Event) error {
+	callCtx, cancel := context.WithTimeout(exporter.authenticator.attach(ctx), exporter.callTimeout)
+	defer cancel()
+
+	stream, err := exporter.client.Collect(callCtx, exporter.callOptions...)
+	if err != nil {
+		return err
+	}
+	for _, swEvent := range batch {
+		if err := stream.Send(swEvent); err != nil {
+			if err == io.EOF {
+				// The real error is returned by CloseAndRecv.
+				break
+			}
+			return err
+		}
 	}
+	_, err = stream.CloseAndRecv()
+	return err
 }
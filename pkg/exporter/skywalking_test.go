@@ -0,0 +1,363 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package exporter
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	common "skywalking.apache.org/repo/goapi/collect/common/v3"
+	sw "skywalking.apache.org/repo/goapi/collect/event/v3"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/configs"
+	"github.com/apache/skywalking-kubernetes-event-exporter/pkg/k8s"
+)
+
+// eventServer records the events and the authentication tokens of every call,
+// and rejects the calls with err if it's set, or the first unavailable calls.
+type eventServer struct {
+	sw.UnimplementedEventServiceServer
+	err error
+
+	mu          sync.Mutex
+	unavailable int
+	batches     [][]string
+	messages    []string
+	tokens      []string
+}
+
+func (s *eventServer) Collect(stream sw.EventService_CollectServer) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	s.mu.Lock()
+	s.tokens = append(s.tokens, strings.Join(md.Get(authenticationKey), ","))
+	s.mu.Unlock()
+
+	var batch, messages []string
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		batch = append(batch, event.Uuid)
+		messages = append(messages, event.Message)
+	}
+	if s.err != nil {
+		return s.err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unavailable > 0 {
+		s.unavailable--
+		return status.Error(codes.Unavailable, "unavailable")
+	}
+	s.batches = append(s.batches, batch)
+	s.messages = append(s.messages, messages...)
+
+	return stream.SendAndClose(&common.Commands{})
+}
+
+func startEventServer(t *testing.T) (*eventServer, *grpc.ClientConn) {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	s := &eventServer{}
+	sw.RegisterEventServiceServer(server, s)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufconn", grpc.WithInsecure(), grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	return s, conn
+}
+
+// received returns the number of the received batches.
+func (s *eventServer) received() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.batches)
+}
+
+// startTCPEventServer starts the server that listens on a random local port.
+func startTCPEventServer(t *testing.T) (*eventServer, *grpc.Server, string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	s := &eventServer{}
+	sw.RegisterEventServiceServer(server, s)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	return s, server, listener.Addr().String()
+}
+
+func TestSkyWalking_balancer(t *testing.T) {
+	tests := []struct {
+		name     string
+		balancer string
+		// wantBoth is whether the batches are sent to both the servers.
+		wantBoth bool
+	}{
+		{name: "round robin", balancer: "roundRobin", wantBoth: true},
+		{name: "failover", balancer: "failover", wantBoth: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server1, grpcServer1, address1 := startTCPEventServer(t)
+			server2, _, address2 := startTCPEventServer(t)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			exporter := &SkyWalking{}
+			if err := exporter.Init(ctx, configs.ExporterConfig{
+				"addresses": []string{address1, address2},
+				"balancer":  tt.balancer,
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 10; i++ {
+				exporter.send(ctx, []*sw.Event{{Uuid: strconv.Itoa(i)}})
+			}
+			if both := server1.received() > 0 && server2.received() > 0; both != tt.wantBoth {
+				t.Errorf("received by server1 = %v, server2 = %v", server1.received(), server2.received())
+			}
+
+			// The batches are sent to the other server once one of the servers is down.
+			grpcServer1.Stop()
+			received1, received2 := server1.received(), server2.received()
+			for i := 0; i < 3; i++ {
+				exporter.send(ctx, []*sw.Event{{Uuid: strconv.Itoa(i)}})
+			}
+			if server1.received() != received1 || server2.received() != received2+3 {
+				t.Errorf("received by server1 = %v, server2 = %v after server1 is down", server1.received()-received1, server2.received()-received2)
+			}
+		})
+	}
+}
+
+func TestSkyWalking_Export(t *testing.T) {
+	tests := []struct {
+		name          string
+		batchSize     int
+		batchInterval time.Duration
+		events        int
+		delay         time.Duration
+		want          [][]string
+	}{
+		{
+			name:          "full batches",
+			batchSize:     2,
+			batchInterval: time.Hour,
+			events:        5,
+			want:          [][]string{{"0", "1"}, {"2", "3"}, {"4"}},
+		},
+		{
+			name:          "batch interval",
+			batchSize:     100,
+			batchInterval: 10 * time.Millisecond,
+			events:        2,
+			delay:         100 * time.Millisecond,
+			want:          [][]string{{"0"}, {"1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, conn := startEventServer(t)
+			exporter := &SkyWalking{
+				config:        SkyWalkingConfig{BatchSize: tt.batchSize},
+				client:        sw.NewEventServiceClient(conn),
+				batchInterval: tt.batchInterval,
+				callTimeout:   time.Second,
+			}
+
+			events := make(chan *k8s.Event)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				exporter.Export(context.Background(), events)
+			}()
+			for i := 0; i < tt.events; i++ {
+				events <- &k8s.Event{Event: &corev1.Event{
+					ObjectMeta: metav1.ObjectMeta{UID: types.UID(string(rune('0' + i)))},
+				}}
+				time.Sleep(tt.delay)
+			}
+			close(events)
+			<-done
+
+			if !reflect.DeepEqual(server.batches, tt.want) {
+				t.Errorf("batches = %v, want %v", server.batches, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkyWalking_authentication(t *testing.T) {
+	fileReloadInterval = 10 * time.Millisecond
+	defer func() {
+		fileReloadInterval = 10 * time.Second
+	}()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("token-1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	auth, err := newAuthenticator(ctx, &AuthenticationConfig{TokenFile: tokenFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, conn := startEventServer(t)
+	exporter := &SkyWalking{
+		client:        sw.NewEventServiceClient(conn),
+		callTimeout:   time.Second,
+		authenticator: auth,
+	}
+
+	exporter.send(ctx, []*sw.Event{{Uuid: "0"}})
+	if err := os.WriteFile(tokenFile, []byte("token-2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	exporter.send(ctx, []*sw.Event{{Uuid: "1"}})
+
+	if want := []string{"token-1", "token-2"}; !reflect.DeepEqual(server.tokens, want) {
+		t.Errorf("tokens = %v, want %v", server.tokens, want)
+	}
+}
+
+func TestSkyWalking_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config configs.ExporterConfig
+		want   []string
+	}{
+		{
+			name: "valid",
+			config: configs.ExporterConfig{
+				"address":        "127.0.0.1:11800",
+				"batchSize":      50,
+				"batchInterval":  "500ms",
+				"compression":    "gzip",
+				"maxMessageSize": 4194304,
+				"timeout":        "5s",
+				"keepalive":      map[string]interface{}{"time": "30s", "timeout": "10s"},
+			},
+		},
+		{
+			name: "invalid",
+			config: configs.ExporterConfig{
+				"address":        "127.0.0.1:11800",
+				"batchSize":      -1,
+				"batchInterval":  "1 second",
+				"compression":    "snappy",
+				"maxMessageSize": -1,
+				"timeout":        "-5s",
+				"keepalive":      map[string]interface{}{"time": "30"},
+			},
+			want: []string{"batchInterval", "keepalive.time", "timeout", "batchSize", "maxMessageSize", "compression"},
+		},
+		{
+			name: "invalid authentication",
+			config: configs.ExporterConfig{
+				"address":        "127.0.0.1:11800",
+				"authentication": map[string]interface{}{"token": "token", "tokenFile": "/not/existed"},
+			},
+			want: []string{"authentication", "authentication.tokenFile"},
+		},
+		{
+			name: "invalid addresses",
+			config: configs.ExporterConfig{
+				"addresses":       []string{"oap-headless.skywalking:11800", "oap"},
+				"resolveInterval": "30",
+				"balancer":        "random",
+			},
+			want: []string{"resolveInterval", "addresses[1]", "balancer"},
+		},
+		{
+			name: "TLS with the system roots",
+			config: configs.ExporterConfig{
+				"address":   "127.0.0.1:11800",
+				"enableTLS": true,
+			},
+		},
+		{
+			name: "invalid TLS",
+			config: configs.ExporterConfig{
+				"address":         "127.0.0.1:11800",
+				"enableTLS":       true,
+				"trustedCertPath": "/not/existed",
+				"minTLSVersion":   "1.1",
+			},
+			want: []string{"trustedCertPath", "minTLSVersion"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields []string
+			for _, err := range (&SkyWalking{}).Validate(tt.config) {
+				fieldErr, ok := err.(*configs.FieldError)
+				if !ok {
+					t.Fatalf("unexpected error %v", err)
+				}
+				fields = append(fields, fieldErr.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.want) {
+				t.Errorf("fields = %v, want %v", fields, tt.want)
+			}
+		})
+	}
+}
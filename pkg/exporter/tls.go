@@ -0,0 +1,136 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package exporter
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+
+	"google.golang.org/grpc/credentials"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/filewatch"
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
+)
+
+// tlsVersions are the supported values of minTLSVersion.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsConfig builds the TLS configurations from the certificate files.
+func (config *SkyWalkingConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{ServerName: config.ServerName}
+	tlsConfig.InsecureSkipVerify = config.InsecureSkipVerify
+
+	if config.ClientCertPath != "" || config.ClientKeyPath != "" {
+		clientCert, err := tls.LoadX509KeyPair(config.ClientCertPath, config.ClientKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+		// TLS 1.3 is required with the client certificates unless minTLSVersion is configured.
+		tlsConfig.MinVersion = tls.VersionTLS13
+	}
+
+	if config.TrustedCertPath != "" {
+		trustedCert, err := os.ReadFile(config.TrustedCertPath)
+		if err != nil {
+			return nil, err
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(trustedCert) {
+			return nil, fmt.Errorf("no certificate is found in %v", config.TrustedCertPath)
+		}
+		tlsConfig.RootCAs = certPool
+	}
+
+	if version, ok := tlsVersions[config.MinTLSVersion]; ok {
+		tlsConfig.MinVersion = version
+	}
+
+	return tlsConfig, nil
+}
+
+// reloadableCredentials are the TLS credentials that are rebuilt when the certificate files change,
+// the new credentials are used by the new connections, while the established ones are kept.
+type reloadableCredentials struct {
+	current atomic.Value // credentials.TransportCredentials
+}
+
+// newTLSCredentials builds the TLS credentials, and keeps reloading the certificate files until the context is done.
+func newTLSCredentials(ctx context.Context, config *SkyWalkingConfig) (credentials.TransportCredentials, error) {
+	tlsConfig, err := config.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	creds := &reloadableCredentials{}
+	creds.current.Store(credentials.NewTLS(tlsConfig))
+
+	for _, path := range []string{config.ClientCertPath, config.ClientKeyPath, config.TrustedCertPath} {
+		if path == "" {
+			continue
+		}
+		go filewatch.Watch(ctx, path, fileReloadInterval, func([]byte) {
+			// The certificate and the key may be changed one after another, the pair
+			// is reloaded again when the other one is changed if it fails now.
+			tlsConfig, err := config.tlsConfig()
+			if err != nil {
+				logger.Log.Warnf("failed to reload the TLS certificates, keep using the previous ones. %+v", err)
+				return
+			}
+			creds.current.Store(credentials.NewTLS(tlsConfig))
+			logger.Log.Infof("the TLS certificates have been reloaded")
+		})
+	}
+
+	return creds, nil
+}
+
+func (creds *reloadableCredentials) load() credentials.TransportCredentials {
+	return creds.current.Load().(credentials.TransportCredentials)
+}
+
+func (creds *reloadableCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return creds.load().ClientHandshake(ctx, authority, conn)
+}
+
+func (creds *reloadableCredentials) ServerHandshake(net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.New("server handshake is not supported")
+}
+
+func (creds *reloadableCredentials) Info() credentials.ProtocolInfo {
+	return creds.load().Info()
+}
+
+func (creds *reloadableCredentials) Clone() credentials.TransportCredentials {
+	return creds
+}
+
+func (creds *reloadableCredentials) OverrideServerName(string) error {
+	return errors.New("overriding server name is not supported, use serverName instead")
+}
@@ -0,0 +1,239 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package exporter
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	sw "skywalking.apache.org/repo/goapi/collect/event/v3"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/configs"
+)
+
+// testCA is a self-signed CA that issues the certificates of 127.0.0.1.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue issues the certificate of 127.0.0.1, and returns the certificate and the key in PEM.
+func (ca *testCA) issue(t *testing.T) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, content []byte) {
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSkyWalkingConfig_tlsConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t)
+	writeFile(t, filepath.Join(dir, "ca.crt"), ca.pem)
+	writeFile(t, filepath.Join(dir, "tls.crt"), certPEM)
+	writeFile(t, filepath.Join(dir, "tls.key"), keyPEM)
+	writeFile(t, filepath.Join(dir, "empty.crt"), nil)
+
+	tests := []struct {
+		name           string
+		config         SkyWalkingConfig
+		wantErr        bool
+		wantMinVersion uint16
+	}{
+		{
+			name:   "system roots",
+			config: SkyWalkingConfig{},
+		},
+		{
+			name:   "trusted cert only",
+			config: SkyWalkingConfig{TrustedCertPath: filepath.Join(dir, "ca.crt")},
+		},
+		{
+			name: "client certs",
+			config: SkyWalkingConfig{
+				TrustedCertPath: filepath.Join(dir, "ca.crt"),
+				ClientCertPath:  filepath.Join(dir, "tls.crt"),
+				ClientKeyPath:   filepath.Join(dir, "tls.key"),
+			},
+			wantMinVersion: tls.VersionTLS13,
+		},
+		{
+			name: "client certs with TLS 1.2",
+			config: SkyWalkingConfig{
+				TrustedCertPath: filepath.Join(dir, "ca.crt"),
+				ClientCertPath:  filepath.Join(dir, "tls.crt"),
+				ClientKeyPath:   filepath.Join(dir, "tls.key"),
+				MinTLSVersion:   "1.2",
+			},
+			wantMinVersion: tls.VersionTLS12,
+		},
+		{
+			name:    "missing trusted cert",
+			config:  SkyWalkingConfig{TrustedCertPath: filepath.Join(dir, "missing.crt")},
+			wantErr: true,
+		},
+		{
+			name:    "empty trusted cert",
+			config:  SkyWalkingConfig{TrustedCertPath: filepath.Join(dir, "empty.crt")},
+			wantErr: true,
+		},
+		{
+			name: "mismatched client cert and key",
+			config: SkyWalkingConfig{
+				TrustedCertPath: filepath.Join(dir, "ca.crt"),
+				ClientCertPath:  filepath.Join(dir, "tls.crt"),
+				ClientKeyPath:   filepath.Join(dir, "ca.crt"),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsConfig, err := tt.config.tlsConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("tlsConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && tlsConfig.MinVersion != tt.wantMinVersion {
+				t.Errorf("MinVersion = %v, want %v", tlsConfig.MinVersion, tt.wantMinVersion)
+			}
+		})
+	}
+}
+
+func TestSkyWalking_tlsReload(t *testing.T) {
+	fileReloadInterval = 10 * time.Millisecond
+	defer func() {
+		fileReloadInterval = 10 * time.Second
+	}()
+
+	oldCA, newCA := newTestCA(t), newTestCA(t)
+	certPEM, keyPEM := newCA.issue(t)
+	serverCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(grpc.Creds(credentials.NewServerTLSFromCert(&serverCert)))
+	sw.RegisterEventServiceServer(server, &eventServer{})
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Stop()
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	writeFile(t, caFile, oldCA.pem)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	exporter := &SkyWalking{}
+	if err := exporter.Init(ctx, configs.ExporterConfig{
+		"address":         listener.Addr().String(),
+		"enableTLS":       true,
+		"trustedCertPath": caFile,
+		"timeout":         "1s",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := exporter.collect(ctx, []*sw.Event{{Uuid: "0"}}); err == nil {
+		t.Fatalf("the server certificate should not be trusted by the old CA")
+	}
+
+	// The certificates of the rotated CA are used for the new connections.
+	writeFile(t, caFile, newCA.pem)
+	var collectErr error
+	for i := 0; i < 50; i++ {
+		time.Sleep(20 * time.Millisecond)
+		if collectErr = exporter.collect(ctx, []*sw.Event{{Uuid: "1"}}); collectErr == nil {
+			break
+		}
+	}
+	if collectErr != nil {
+		t.Errorf("failed to send events after the CA is rotated. %v", collectErr)
+	}
+}
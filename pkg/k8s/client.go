@@ -20,14 +20,36 @@
 package k8s
 
 import (
-	"os"
-	"path/filepath"
+	"context"
+	"fmt"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
+// ClientOptions are the options to connect to the Kubernetes API server,
+// they are typically set from the command line flags.
+type ClientOptions struct {
+	// Kubeconfig is the path to the kubeconfig file, when it's empty, the standard
+	// loading rules apply ($KUBECONFIG, then $HOME/.kube/config, then in-cluster config).
+	Kubeconfig string
+	// Context is the kubeconfig context to use, empty means the current context.
+	Context string
+	// Master overrides the address of the Kubernetes API server in the kubeconfig.
+	Master string
+	// QPS is the maximum queries per second to the API server, 0 means client-go's default.
+	QPS float32
+	// Burst is the maximum burst for throttle, 0 means client-go's default.
+	Burst int
+	// UserAgent is the user agent sent to the API server, empty means client-go's default.
+	UserAgent string
+}
+
+// Options are the global options to connect to the Kubernetes API server.
+var Options = &ClientOptions{}
+
 func GetClient() (*kubernetes.Clientset, error) {
 	config, err := GetConfig()
 	if err != nil {
@@ -38,12 +60,78 @@ func GetClient() (*kubernetes.Clientset, error) {
 
 // GetConfig returns the configuration to connect to the Kubernetes API server.
 func GetConfig() (*rest.Config, error) {
-	if config, err := rest.InClusterConfig(); err == nil {
-		return config, nil
-	} else if err != rest.ErrNotInCluster {
+	return Options.Config()
+}
+
+// Config returns the configuration to connect to the Kubernetes API server,
+// built with client-go's standard loading rules and overridden by the options.
+func (o *ClientOptions) Config() (*rest.Config, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = o.Kubeconfig
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, o.overrides()).ClientConfig()
+	if err != nil {
 		return nil, err
 	}
 
-	// Not in cluster, try other conventional config
-	return clientcmd.BuildConfigFromFlags("", filepath.Join(os.Getenv("HOME"), ".kube", "config"))
+	return o.apply(config), nil
+}
+
+// ConfigFromKubeconfig returns the configuration to connect to the Kubernetes API server,
+// built from the content of a kubeconfig file and overridden by the options.
+func (o *ClientOptions) ConfigFromKubeconfig(content []byte) (*rest.Config, error) {
+	kubeconfig, err := clientcmd.Load(content)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := clientcmd.NewNonInteractiveClientConfig(*kubeconfig, o.Context, o.overrides(), nil).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return o.apply(config), nil
+}
+
+// ConfigFromSecret returns the configuration to connect to the Kubernetes API server,
+// built from the kubeconfig stored in the key of the Secret, the Secret itself is read
+// with the global Options.
+func (o *ClientOptions) ConfigFromSecret(ctx context.Context, namespace, name, key string) (*rest.Config, error) {
+	client, err := GetClient()
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	content, ok := secret.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("key %v is not found in secret %v/%v", key, namespace, name)
+	}
+
+	return o.ConfigFromKubeconfig(content)
+}
+
+func (o *ClientOptions) overrides() *clientcmd.ConfigOverrides {
+	overrides := &clientcmd.ConfigOverrides{
+		CurrentContext: o.Context,
+	}
+	overrides.ClusterInfo.Server = o.Master
+	return overrides
+}
+
+func (o *ClientOptions) apply(config *rest.Config) *rest.Config {
+	if o.QPS > 0 {
+		config.QPS = o.QPS
+	}
+	if o.Burst > 0 {
+		config.Burst = o.Burst
+	}
+	if o.UserAgent != "" {
+		config.UserAgent = o.UserAgent
+	}
+	return config
 }
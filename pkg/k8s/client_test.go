@@ -0,0 +1,114 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package k8s
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testKubeconfig = `
+apiVersion: v1
+kind: Config
+current-context: staging
+clusters:
+  - name: staging
+    cluster:
+      server: https://staging.example.com:6443
+  - name: production
+    cluster:
+      server: https://production.example.com:6443
+users:
+  - name: admin
+    user:
+      token: token
+contexts:
+  - name: staging
+    context:
+      cluster: staging
+      user: admin
+  - name: production
+    context:
+      cluster: production
+      user: admin
+`
+
+func TestClientOptions_Config(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(kubeconfig, []byte(testKubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		options       ClientOptions
+		wantHost      string
+		wantQPS       float32
+		wantBurst     int
+		wantUserAgent string
+	}{
+		{
+			name:     "current context",
+			options:  ClientOptions{Kubeconfig: kubeconfig},
+			wantHost: "https://staging.example.com:6443",
+		},
+		{
+			name:     "context",
+			options:  ClientOptions{Kubeconfig: kubeconfig, Context: "production"},
+			wantHost: "https://production.example.com:6443",
+		},
+		{
+			name:     "master",
+			options:  ClientOptions{Kubeconfig: kubeconfig, Context: "production", Master: "https://127.0.0.1:6443"},
+			wantHost: "https://127.0.0.1:6443",
+		},
+		{
+			name:          "qps, burst and user agent",
+			options:       ClientOptions{Kubeconfig: kubeconfig, QPS: 50, Burst: 100, UserAgent: "event-exporter"},
+			wantHost:      "https://staging.example.com:6443",
+			wantQPS:       50,
+			wantBurst:     100,
+			wantUserAgent: "event-exporter",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := tt.options.Config()
+			if err != nil {
+				t.Fatalf("Config() error = %v", err)
+			}
+			if config.Host != tt.wantHost {
+				t.Errorf("Host = %v, want %v", config.Host, tt.wantHost)
+			}
+			if config.QPS != tt.wantQPS || config.Burst != tt.wantBurst || config.UserAgent != tt.wantUserAgent {
+				t.Errorf("QPS, Burst, UserAgent = %v, %v, %q, want %v, %v, %q",
+					config.QPS, config.Burst, config.UserAgent, tt.wantQPS, tt.wantBurst, tt.wantUserAgent)
+			}
+			if config.BearerToken != "token" {
+				t.Errorf("BearerToken = %v, want token", config.BearerToken)
+			}
+		})
+	}
+
+	if _, err := (&ClientOptions{Kubeconfig: kubeconfig, Context: "missing"}).Config(); err == nil {
+		t.Errorf("Config() with a missing context error = nil, want error")
+	}
+}
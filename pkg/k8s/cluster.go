@@ -0,0 +1,70 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package k8s
+
+import (
+	"context"
+
+	"k8s.io/client-go/rest"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
+)
+
+// Cluster is a Kubernetes cluster that the exporter watches events from,
+// each cluster has its own event watcher and template context registry,
+// sharing the informers of the cluster.
+type Cluster struct {
+	Name      string
+	Watcher   *EventWatcher
+	informers *SharedInformers
+	registry  *registry
+}
+
+// NewCluster creates a cluster with the given name, the events are watched with the given
+// configuration and informer options, and enriched by the registry configured with the given options.
+func NewCluster(name string, config *rest.Config, informerOptions InformerOptions, registryOptions RegistryOptions) (*Cluster, error) {
+	informers, err := NewSharedInformers(config, informerOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &registry{}
+	if err := r.Init(informers, registryOptions); err != nil {
+		return nil, err
+	}
+
+	return &Cluster{
+		Name:      name,
+		Watcher:   WatchEvents(name, informers),
+		informers: informers,
+		registry:  r,
+	}, nil
+}
+
+// Start starts the event watcher and the informers of the cluster,
+// and registers the registry so that events of this cluster can be enriched.
+func (c *Cluster) Start(ctx context.Context) {
+	logger.Log.Debugf("starting cluster %+v", c.Name)
+
+	Registry.add(c.Name, c.registry)
+
+	c.Watcher.Start(ctx)
+	c.informers.Start(ctx)
+}
@@ -0,0 +1,86 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package k8s
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// defaultGVK is used when the decoded object doesn't specify its apiVersion or kind,
+// so that the recorded events without type information can still be decoded.
+var defaultGVK = schema.GroupVersionKind{Version: "v1", Kind: "Event"}
+
+// DecodeObjects decodes the Kubernetes objects from the content, which can be a JSON or YAML
+// object, an array of objects, a List like the output of `kubectl get -o json`, or a stream of
+// JSON Lines or multi-document YAML.
+func DecodeObjects(content []byte) ([]runtime.Object, error) {
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 4096)
+
+	var objects []runtime.Object
+	for {
+		var doc interface{}
+		if err := decoder.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		for _, item := range flatten(doc) {
+			raw, err := json.Marshal(item)
+			if err != nil {
+				return nil, err
+			}
+			obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(raw, &defaultGVK, nil)
+			if runtime.IsNotRegisteredError(err) {
+				// Custom resources are decoded as unstructured objects.
+				obj, _, err = unstructured.UnstructuredJSONScheme.Decode(raw, nil, nil)
+			}
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, obj)
+		}
+	}
+
+	return objects, nil
+}
+
+// flatten returns the items if the doc is an array or a List, otherwise the doc itself.
+func flatten(doc interface{}) []interface{} {
+	switch d := doc.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return d
+	case map[string]interface{}:
+		if items, ok := d["items"].([]interface{}); ok {
+			return items
+		}
+	}
+	return []interface{}{doc}
+}
@@ -0,0 +1,144 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package k8s
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+func TestDecodeObjects(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "multi-document YAML",
+			content: `
+apiVersion: v1
+kind: Event
+metadata:
+  name: reviews.1
+reason: Pulled
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: reviews
+`,
+			want: []string{"*v1.Event reviews.1", "*v1.Pod reviews"},
+		},
+		{
+			name:    "JSON object without type information",
+			content: `{"metadata": {"name": "reviews.1"}, "reason": "Pulled"}`,
+			want:    []string{"*v1.Event reviews.1"},
+		},
+		{
+			name:    "JSON array",
+			content: `[{"metadata": {"name": "reviews.1"}}, {"apiVersion": "v1", "kind": "Node", "metadata": {"name": "node-1"}}]`,
+			want:    []string{"*v1.Event reviews.1", "*v1.Node node-1"},
+		},
+		{
+			name: "v1 List",
+			content: `{"apiVersion": "v1", "kind": "List", "items": [
+				{"apiVersion": "v1", "kind": "Event", "metadata": {"name": "reviews.1"}},
+				{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "reviews"}}
+			]}`,
+			want: []string{"*v1.Event reviews.1", "*v1.Deployment reviews"},
+		},
+		{
+			name: "EventList of kubectl get events -o json",
+			content: `{"apiVersion": "v1", "kind": "EventList", "items": [
+				{"metadata": {"name": "reviews.1"}}, {"metadata": {"name": "reviews.2"}}
+			]}`,
+			want: []string{"*v1.Event reviews.1", "*v1.Event reviews.2"},
+		},
+		{
+			name: "JSON Lines",
+			content: `{"metadata": {"name": "reviews.1"}}
+{"metadata": {"name": "reviews.2"}}
+`,
+			want: []string{"*v1.Event reviews.1", "*v1.Event reviews.2"},
+		},
+		{
+			name:    "custom resource",
+			content: `{"apiVersion": "example.com/v1", "kind": "Database", "metadata": {"name": "orders"}}`,
+			want:    []string{"*unstructured.Unstructured orders"},
+		},
+		{
+			name: "empty",
+		},
+		{
+			name:    "malformed JSON",
+			content: `{"metadata": {"name": "reviews.1"`,
+			wantErr: true,
+		},
+		{
+			name: "malformed YAML",
+			content: `
+metadata:
+  name: [reviews.1
+`,
+			wantErr: true,
+		},
+		{
+			name: "malformed JSON Lines",
+			content: `{"metadata": {"name": "reviews.1"}}
+{"metadata": 
+`,
+			wantErr: true,
+		},
+		{
+			name:    "not an object",
+			content: `"reviews.1"`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid field",
+			content: `{"metadata": {"name": "reviews.1"}, "count": "many"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := DecodeObjects([]byte(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeObjects() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var got []string
+			for _, obj := range objects {
+				accessor, err := meta.Accessor(obj)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, fmt.Sprintf("%T %v", obj, accessor.GetName()))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeObjects() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package k8s
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	discoveryv1beta1 "k8s.io/api/discovery/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
+)
+
+// podIndex indexes the endpoints of the Services, i.e. the EndpointSlices or the Endpoints, by the pods
+// that their addresses refer to, in the form of namespace/name.
+const podIndex = "pod"
+
+// discoveryBackoff is the backoff to retry discovering the EndpointSlices when the discovery fails.
+var discoveryBackoff = wait.Backoff{Duration: time.Second, Factor: 2, Jitter: 0.1, Steps: 5}
+
+// endpointsInformer returns the informer of the EndpointSlices, or the Endpoints if the cluster doesn't
+// serve EndpointSlices, the Endpoints are truncated at 1000 addresses so EndpointSlices are preferred.
+// The discovery is retried when it fails, rather than falling back to the Endpoints for good.
+func endpointsInformer(factory informers.SharedInformerFactory, client discovery.DiscoveryInterface) (cache.SharedIndexInformer, error) {
+	var informer cache.SharedIndexInformer
+	err := retry.OnError(discoveryBackoff, func(err error) bool {
+		logger.Log.Warnf("failed to discover EndpointSlices, retrying. %+v", err)
+		return true
+	}, func() (err error) {
+		informer, err = discoverEndpointsInformer(factory, client)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover EndpointSlices: %w", err)
+	}
+	return informer, nil
+}
+
+func discoverEndpointsInformer(factory informers.SharedInformerFactory, client discovery.DiscoveryInterface) (cache.SharedIndexInformer, error) {
+	if served, err := serves(client, discoveryv1.SchemeGroupVersion.WithResource("endpointslices")); err != nil || served {
+		return factory.Discovery().V1().EndpointSlices().Informer(), err
+	}
+	if served, err := serves(client, discoveryv1beta1.SchemeGroupVersion.WithResource("endpointslices")); err != nil || served {
+		return factory.Discovery().V1beta1().EndpointSlices().Informer(), err
+	}
+
+	logger.Log.Warnf("%v is not served by the cluster, watching Endpoints instead", schema.GroupResource{Group: discoveryv1.GroupName, Resource: "endpointslices"})
+
+	return factory.Core().V1().Endpoints().Informer(), nil
+}
+
+func endpointsPods(obj interface{}) ([]string, error) {
+	var pods []string
+	add := func(ref *corev1.ObjectReference) {
+		if ref != nil && ref.Kind == "Pod" {
+			pods = append(pods, ref.Namespace+"/"+ref.Name)
+		}
+	}
+
+	switch o := obj.(type) {
+	case *discoveryv1.EndpointSlice:
+		for _, endpoint := range o.Endpoints {
+			add(endpoint.TargetRef)
+		}
+	case *discoveryv1beta1.EndpointSlice:
+		for _, endpoint := range o.Endpoints {
+			add(endpoint.TargetRef)
+		}
+	case *corev1.Endpoints:
+		for _, subset := range o.Subsets {
+			for _, addresses := range [][]corev1.EndpointAddress{subset.Addresses, subset.NotReadyAddresses} {
+				for i := range addresses {
+					add(addresses[i].TargetRef)
+				}
+			}
+		}
+	}
+
+	return pods, nil
+}
+
+// serviceNameOf returns the name of the Service that the EndpointSlice or the Endpoints belong to,
+// a Service can have several EndpointSlices, e.g. one for each address family of the dual-stack Service.
+func serviceNameOf(obj interface{}) string {
+	switch o := obj.(type) {
+	case *discoveryv1.EndpointSlice:
+		return o.Labels[discoveryv1.LabelServiceName]
+	case *discoveryv1beta1.EndpointSlice:
+		return o.Labels[discoveryv1beta1.LabelServiceName]
+	case *corev1.Endpoints:
+		return o.Name
+	}
+	return ""
+}
@@ -0,0 +1,115 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package k8s
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+// failingDiscovery fails to discover the resources for the first `failures` times.
+type failingDiscovery struct {
+	*fakediscovery.FakeDiscovery
+	failures int
+}
+
+func (d *failingDiscovery) ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error) {
+	if d.failures > 0 {
+		d.failures--
+		return nil, errors.New("connection refused")
+	}
+	return d.FakeDiscovery.ServerResourcesForGroupVersion(groupVersion)
+}
+
+func TestEndpointsInformer(t *testing.T) {
+	defer func(backoff wait.Backoff) { discoveryBackoff = backoff }(discoveryBackoff)
+	discoveryBackoff = wait.Backoff{Duration: time.Millisecond, Steps: 3}
+
+	newDiscovery := func(groupVersions ...string) *fakediscovery.FakeDiscovery {
+		fake := &clienttesting.Fake{}
+		for _, groupVersion := range groupVersions {
+			fake.Resources = append(fake.Resources, &metav1.APIResourceList{
+				GroupVersion: groupVersion, APIResources: []metav1.APIResource{{Name: "endpointslices"}},
+			})
+		}
+		return &fakediscovery.FakeDiscovery{Fake: fake}
+	}
+
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	tests := []struct {
+		name    string
+		client  discovery.DiscoveryInterface
+		want    cache.SharedIndexInformer
+		wantErr bool
+	}{
+		{
+			name:   "discovery/v1",
+			client: newDiscovery("discovery.k8s.io/v1", "discovery.k8s.io/v1beta1"),
+			want:   factory.Discovery().V1().EndpointSlices().Informer(),
+		},
+		{
+			name:   "discovery/v1beta1",
+			client: newDiscovery("discovery.k8s.io/v1beta1"),
+			want:   factory.Discovery().V1beta1().EndpointSlices().Informer(),
+		},
+		{
+			name:   "not served",
+			client: newDiscovery(),
+			want:   factory.Core().V1().Endpoints().Informer(),
+		},
+		{
+			name:   "not served by the cached discovery",
+			client: memory.NewMemCacheClient(newDiscovery()),
+			want:   factory.Core().V1().Endpoints().Informer(),
+		},
+		{
+			name:   "discovery recovers",
+			client: &failingDiscovery{FakeDiscovery: newDiscovery("discovery.k8s.io/v1"), failures: 2},
+			want:   factory.Discovery().V1().EndpointSlices().Informer(),
+		},
+		{
+			name:    "discovery keeps failing",
+			client:  &failingDiscovery{FakeDiscovery: newDiscovery("discovery.k8s.io/v1"), failures: 3},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := endpointsInformer(factory, tt.client)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("endpointsInformer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("endpointsInformer() = %T of %v, want %v", got, got, tt.want)
+			}
+		})
+	}
+}
@@ -24,56 +24,52 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 
-	"k8s.io/client-go/informers"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
-
 	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
 )
 
+// Event is a Kubernetes event along with the name of the cluster where it happens.
+type Event struct {
+	*v1.Event
+	Cluster string
+}
+
 type EventWatcher struct {
-	Events   chan *v1.Event
-	informer cache.SharedIndexInformer
+	Events  chan *Event
+	cluster string
 }
 
 func (w EventWatcher) OnAdd(obj interface{}) {
-	w.Events <- obj.(*v1.Event)
+	w.Events <- &Event{Event: obj.(*v1.Event), Cluster: w.cluster}
 }
 
 func (w EventWatcher) OnUpdate(_, newObj interface{}) {
-	w.Events <- newObj.(*v1.Event)
+	w.Events <- &Event{Event: newObj.(*v1.Event), Cluster: w.cluster}
 }
 
 func (w EventWatcher) OnDelete(_ interface{}) {
 }
 
 func (w EventWatcher) Start(ctx context.Context) {
-	logger.Log.Debugf("starting event watcher")
-
-	go w.informer.Run(ctx.Done())
+	logger.Log.Debugf("starting event watcher of cluster %+v", w.cluster)
 
 	go func() {
 		<-ctx.Done()
 
-		logger.Log.Debugf("stopping event watcher")
+		logger.Log.Debugf("stopping event watcher of cluster %+v", w.cluster)
 	}()
 }
 
-func WatchEvents(_ context.Context, ns string) (*EventWatcher, error) {
-	config, err := GetConfig()
-	if err != nil {
-		return nil, err
-	}
-	client := kubernetes.NewForConfigOrDie(config)
-	factory := informers.NewSharedInformerFactoryWithOptions(client, 0, informers.WithNamespace(ns))
-	informer := factory.Core().V1().Events().Informer()
+// WatchEvents watches the events of the cluster with the shared informers,
+// the events are sent to the Events channel once the shared informers are started.
+func WatchEvents(cluster string, shared *SharedInformers) *EventWatcher {
+	informer := shared.Factory.Core().V1().Events().Informer()
 
 	watcher := &EventWatcher{
-		informer: informer,
-		Events:   make(chan *v1.Event),
+		cluster: cluster,
+		Events:  make(chan *Event),
 	}
 
 	informer.AddEventHandler(watcher)
 
-	return watcher, nil
+	return watcher
 }
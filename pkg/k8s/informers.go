@@ -0,0 +1,102 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package k8s
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+// InformerOptions configures the shared informers of a cluster.
+type InformerOptions struct {
+	// Namespace limits the namespaced objects to watch, empty means all the namespaces.
+	Namespace string
+	// Resync is the resync period of the informers, 0 disables resyncing.
+	Resync time.Duration
+}
+
+// SharedInformers are the clients and the informer factories of a cluster, they are shared by the
+// event watcher, the registry and the other enrichers, so that there is only one connection pool to
+// the cluster, and every kind of objects is watched only once.
+type SharedInformers struct {
+	Client    kubernetes.Interface
+	Metadata  metadata.Interface
+	Dynamic   dynamic.Interface
+	Discovery discovery.CachedDiscoveryInterface
+	Mapper    meta.RESTMapper
+
+	Factory         informers.SharedInformerFactory
+	MetadataFactory metadatainformer.SharedInformerFactory
+	DynamicFactory  dynamicinformer.DynamicSharedInformerFactory
+}
+
+// NewSharedInformers creates the clients sharing one HTTP client, and the informer factories of the cluster.
+func NewSharedInformers(config *rest.Config, options InformerOptions) (*SharedInformers, error) {
+	httpClient, err := rest.HTTPClientFor(config)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kubernetes.NewForConfigAndClient(config, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	metadataClient, err := metadata.NewForConfigAndClient(config, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfigAndClient(config, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	discoveryClient := memory.NewMemCacheClient(client.Discovery())
+
+	return &SharedInformers{
+		Client:    client,
+		Metadata:  metadataClient,
+		Dynamic:   dynamicClient,
+		Discovery: discoveryClient,
+		Mapper:    restmapper.NewDeferredDiscoveryRESTMapper(discoveryClient),
+
+		Factory:         informers.NewSharedInformerFactoryWithOptions(client, options.Resync, informers.WithNamespace(options.Namespace)),
+		MetadataFactory: metadatainformer.NewFilteredSharedInformerFactory(metadataClient, options.Resync, options.Namespace, nil),
+		DynamicFactory:  dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, options.Resync, options.Namespace, nil),
+	}, nil
+}
+
+// Start starts all the informers that have been requested from the factories,
+// the informers requested later are started by calling Start again.
+func (s *SharedInformers) Start(ctx context.Context) {
+	s.Factory.Start(ctx.Done())
+	s.MetadataFactory.Start(ctx.Done())
+	s.DynamicFactory.Start(ctx.Done())
+}
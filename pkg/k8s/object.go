@@ -0,0 +1,194 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
+)
+
+// Object is the involved object of an event, of any kind, the fields can be
+// accessed by FieldOf, like `spec.storageClassName`.
+type Object = unstructured.Unstructured
+
+// FieldOf returns the field of the object at the dot-separated path, like `spec.storageClassName`,
+// or empty string if the field doesn't exist, the values that are not string are formatted.
+func FieldOf(o *Object, path string) string {
+	if o == nil {
+		return ""
+	}
+	value, found, err := unstructured.NestedFieldNoCopy(o.Object, strings.Split(path, ".")...)
+	if err != nil || !found || value == nil {
+		return ""
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+// objectCacheTTL is how long the involved objects fetched on demand are cached,
+// the objects of the watched kinds are always up to date in the informers.
+const objectCacheTTL = time.Minute
+
+type objectKey struct {
+	groupKind schema.GroupKind
+	namespace string
+	name      string
+}
+
+type cachedObject struct {
+	object *Object
+	expiry time.Time
+}
+
+// initObjectInformers creates the dynamic informers of the given kinds, in the form of `Kind.group`
+// like `Ingress.networking.k8s.io`.
+func initObjectInformers(shared *SharedInformers, kinds []string) map[schema.GroupKind]cache.SharedIndexInformer {
+	informers := map[schema.GroupKind]cache.SharedIndexInformer{}
+	for _, kind := range kinds {
+		groupKind := schema.ParseGroupKind(kind)
+		mapping, err := shared.Mapper.RESTMapping(groupKind)
+		if err != nil {
+			logger.Log.Warnf("%v is not served by the cluster, skip watching it. %+v", kind, err)
+			continue
+		}
+
+		informers[groupKind] = shared.DynamicFactory.ForResource(mapping.Resource).Informer()
+	}
+
+	return informers
+}
+
+// objectStoreKind is the kind of the store of the watched objects of the group kind, which is prefixed
+// so that it never collides with the kinds of the template context, like Pod.
+func objectStoreKind(groupKind schema.GroupKind) string {
+	return "Object/" + groupKind.String()
+}
+
+// toObject converts the typed object into an Object.
+func toObject(obj runtime.Object) (*Object, error) {
+	if o, ok := obj.(*Object); ok {
+		return o, nil
+	}
+
+	kinds, _, err := scheme.Scheme.ObjectKinds(obj)
+	if err != nil {
+		return nil, err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Object{Object: content}
+	o.SetGroupVersionKind(kinds[0])
+	return o, nil
+}
+
+// addStaticObject adds the object to the cache of the static registry, which never expires.
+func (r *registry) addStaticObject(obj runtime.Object) {
+	o, err := toObject(obj)
+	if err != nil {
+		logger.Log.Debugf("failed to convert object %T. %+v", obj, err)
+		return
+	}
+
+	key := objectKey{groupKind: o.GroupVersionKind().GroupKind(), namespace: o.GetNamespace(), name: o.GetName()}
+	r.idObjectMap.Add(key, &cachedObject{object: o})
+}
+
+// getObject returns the referred object from the informers of the watched kinds, waiting for them
+// to be synced and the object to appear like the objects of the template context, or from the cache,
+// or fetches it with the dynamic client, which is also the fallback of the objects missing in the informers.
+func (r *registry) getObject(ctx context.Context, ref *corev1.ObjectReference) (*Object, error) {
+	namespace := ref.Namespace
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+	key := objectKey{groupKind: gv.WithKind(ref.Kind).GroupKind(), namespace: namespace, name: ref.Name}
+
+	if kind := objectStoreKind(key.groupKind); r.stores[kind] != nil {
+		storeKey := ref.Name
+		if namespace != "" {
+			storeKey = namespace + "/" + ref.Name
+		}
+		obj, err := r.waitFor(ctx, kind, storeKey)
+		if o, ok := obj.(*Object); err == nil && ok {
+			return o, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		logger.Log.Debugf("%v %v is not found in the informer, fetching it. %+v", ref.Kind, storeKey, err)
+	}
+
+	if cached, ok := r.idObjectMap.Get(key); ok {
+		if c := cached.(*cachedObject); r.static || time.Now().Before(c.expiry) {
+			return c.object, nil
+		}
+	}
+	if r.static || r.dynamicClient == nil {
+		return nil, fmt.Errorf("%v %v/%v is not found", ref.Kind, namespace, ref.Name)
+	}
+
+	mapping, err := r.restMapper.RESTMapping(key.groupKind, gv.Version)
+	if err != nil {
+		return nil, err
+	}
+	var resource dynamic.ResourceInterface = r.dynamicClient.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		resource = r.dynamicClient.Resource(mapping.Resource).Namespace(namespace)
+	}
+	obj, err := resource.Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	r.idObjectMap.Add(key, &cachedObject{object: obj, expiry: time.Now().Add(objectCacheTTL)})
+
+	return obj, nil
+}
+
+// typedObject converts the typed object of the template context into an Object,
+// or returns an empty Object if it cannot be converted.
+func typedObject(obj runtime.Object) *Object {
+	o, err := toObject(obj)
+	if err != nil {
+		logger.Log.Debugf("failed to convert object %T. %+v", obj, err)
+		return &Object{Object: map[string]interface{}{}}
+	}
+	return o
+}
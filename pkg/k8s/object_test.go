@@ -0,0 +1,168 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package k8s
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+var ingressGVR = schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"}
+
+func newIngress(name, class string) *Object {
+	o := &Object{Object: map[string]interface{}{"spec": map[string]interface{}{"ingressClassName": class}}}
+	o.SetAPIVersion("networking.k8s.io/v1")
+	o.SetKind("Ingress")
+	o.SetNamespace("default")
+	o.SetName(name)
+	return o
+}
+
+// newObjectRegistry returns the registry that fetches the Ingresses on demand from the fake dynamic client,
+// and watches them if watched is true, whose cache is synced once synced is 1.
+func newObjectRegistry(t *testing.T, watched bool, synced *int32, served ...runtime.Object) *registry {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"}, meta.RESTScopeNamespace)
+
+	r := &registry{
+		stores:        map[string]cache.Indexer{},
+		synced:        map[string]cache.InformerSynced{},
+		notifier:      newNotifier(),
+		restMapper:    mapper,
+		dynamicClient: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{ingressGVR: "IngressList"}, served...),
+	}
+	if err := r.initCaches(); err != nil {
+		t.Fatal(err)
+	}
+	if watched {
+		kind := objectStoreKind(schema.GroupKind{Group: "networking.k8s.io", Kind: "Ingress"})
+		r.stores[kind] = cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+		r.synced[kind] = func() bool { return atomic.LoadInt32(synced) == 1 }
+	}
+	return r
+}
+
+func TestRegistry_getObject_watched(t *testing.T) {
+	defer func(timeout time.Duration) {
+		objectAppearTimeout = timeout
+	}(objectAppearTimeout)
+	objectAppearTimeout = 200 * time.Millisecond
+
+	kind := objectStoreKind(schema.GroupKind{Group: "networking.k8s.io", Kind: "Ingress"})
+	ref := &corev1.ObjectReference{APIVersion: "networking.k8s.io/v1", Kind: "Ingress", Namespace: "default", Name: "reviews"}
+
+	tests := []struct {
+		name string
+		// syncDelay is when the cache is synced, and addDelay is when the object is added to the store,
+		// negative means never.
+		syncDelay time.Duration
+		addDelay  time.Duration
+		served    []runtime.Object
+		wantClass string
+		wantErr   bool
+	}{
+		{name: "object exists", wantClass: "nginx"},
+		{name: "object is added before the cache is synced", syncDelay: 100 * time.Millisecond, addDelay: 50 * time.Millisecond, wantClass: "nginx"},
+		{name: "object appears after the lookup starts", addDelay: 50 * time.Millisecond, wantClass: "nginx"},
+		{name: "object missing in the informer is fetched", addDelay: -1, served: []runtime.Object{newIngress("reviews", "traefik")}, wantClass: "traefik"},
+		{name: "object is not found anywhere", addDelay: -1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var synced int32
+			r := newObjectRegistry(t, true, &synced, tt.served...)
+			add := func() {
+				ingress := newIngress("reviews", "nginx")
+				_ = r.stores[kind].Add(ingress)
+				r.handler(kind).OnAdd(ingress)
+			}
+			if tt.syncDelay == 0 {
+				atomic.StoreInt32(&synced, 1)
+			} else {
+				time.AfterFunc(tt.syncDelay, func() { atomic.StoreInt32(&synced, 1) })
+			}
+			if tt.addDelay == 0 {
+				add()
+			} else if tt.addDelay > 0 {
+				time.AfterFunc(tt.addDelay, add)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			obj, err := r.getObject(ctx, ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getObject() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && FieldOf(obj, "spec.ingressClassName") != tt.wantClass {
+				t.Errorf("getObject() = %v, want the Ingress of class %v", obj, tt.wantClass)
+			}
+		})
+	}
+}
+
+func TestRegistry_getObject_onDemand(t *testing.T) {
+	r := newObjectRegistry(t, false, nil, newIngress("reviews", "nginx"))
+	ref := &corev1.ObjectReference{APIVersion: "networking.k8s.io/v1", Kind: "Ingress", Namespace: "default", Name: "reviews"}
+
+	obj, err := r.getObject(context.Background(), ref)
+	if err != nil || FieldOf(obj, "spec.ingressClassName") != "nginx" {
+		t.Fatalf("getObject() = %v, %v, want the fetched Ingress", obj, err)
+	}
+
+	// The fetched object is cached, so it's served even if it's deleted.
+	if err := r.dynamicClient.Resource(ingressGVR).Namespace("default").Delete(context.Background(), "reviews", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if obj, err := r.getObject(context.Background(), ref); err != nil || obj.GetName() != "reviews" {
+		t.Errorf("getObject() = %v, %v, want the cached Ingress", obj, err)
+	}
+
+	missing := &corev1.ObjectReference{APIVersion: "networking.k8s.io/v1", Kind: "Ingress", Namespace: "default", Name: "details"}
+	if _, err := r.getObject(context.Background(), missing); err == nil {
+		t.Errorf("getObject() of a missing object error = nil, want error")
+	}
+}
+
+func TestRegistry_getObject_static(t *testing.T) {
+	r := &registry{static: true}
+	if err := r.initCaches(); err != nil {
+		t.Fatal(err)
+	}
+	r.addStaticObject(newIngress("reviews", "nginx"))
+
+	ref := &corev1.ObjectReference{APIVersion: "networking.k8s.io/v1", Kind: "Ingress", Namespace: "default", Name: "reviews"}
+	if obj, err := r.getObject(context.Background(), ref); err != nil || FieldOf(obj, "spec.ingressClassName") != "nginx" {
+		t.Errorf("getObject() = %v, %v, want the static Ingress", obj, err)
+	}
+	ref.Name = "details"
+	if _, err := r.getObject(context.Background(), ref); err == nil {
+		t.Errorf("getObject() of a missing object error = nil, want error")
+	}
+}
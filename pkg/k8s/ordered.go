@@ -0,0 +1,122 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package k8s
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultWorkers is the default number of the workers of OrderedWorkers.
+const DefaultWorkers = 16
+
+// workerQueueSize is the number of the events that can be queued for a worker.
+const workerQueueSize = 64
+
+// OrderedWorkers handles the events with a fixed number of workers, the events of the same
+// involved object are always handled by the same worker, so they are handled in order,
+// while the events of different objects are handled concurrently.
+type OrderedWorkers struct {
+	queues  []chan *Event
+	wg      sync.WaitGroup
+	dropped int64
+}
+
+// StartOrderedWorkers starts n workers, or DefaultWorkers if n is not positive, to handle the
+// events until the context is done or the workers are stopped.
+func StartOrderedWorkers(ctx context.Context, n int, handle func(*Event)) *OrderedWorkers {
+	if n <= 0 {
+		n = DefaultWorkers
+	}
+
+	workers := &OrderedWorkers{queues: make([]chan *Event, n)}
+	for i := range workers.queues {
+		queue := make(chan *Event, workerQueueSize)
+		workers.queues[i] = queue
+
+		workers.wg.Add(1)
+		go func() {
+			defer workers.wg.Done()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case e, ok := <-queue:
+					if !ok {
+						return
+					}
+					if ctx.Err() != nil {
+						atomic.AddInt64(&workers.dropped, 1)
+						return
+					}
+					handle(e)
+				}
+			}
+		}()
+	}
+
+	return workers
+}
+
+// Dispatch queues the event to the worker of its involved object, it blocks if the worker is busy,
+// and returns false if the event is dropped because the context is done. It must not be called after Stop.
+func (workers *OrderedWorkers) Dispatch(ctx context.Context, e *Event) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(e.InvolvedObjectKey()))
+
+	select {
+	case workers.queues[hash.Sum32()%uint32(len(workers.queues))] <- e:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Stop waits for all the queued events to be handled, or the context to be done,
+// and returns the number of the queued events that are dropped because the context is done.
+func (workers *OrderedWorkers) Stop() (dropped int) {
+	for _, queue := range workers.queues {
+		close(queue)
+	}
+	workers.wg.Wait()
+
+	dropped = int(atomic.LoadInt64(&workers.dropped))
+	for _, queue := range workers.queues {
+		dropped += len(queue)
+	}
+	return dropped
+}
+
+// InvolvedObjectKey returns the UID of the involved object, or its cluster, kind, namespace
+// and name if the UID is absent, like the events that are recorded without it.
+func (e *Event) InvolvedObjectKey() string {
+	object := e.InvolvedObject
+	if object.UID != "" {
+		return string(object.UID)
+	}
+	return e.Cluster + "/" + object.Kind + "/" + object.Namespace + "/" + object.Name
+}
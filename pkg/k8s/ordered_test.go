@@ -0,0 +1,117 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package k8s
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestOrderedWorkers(t *testing.T) {
+	var mu sync.Mutex
+	handled := map[types.UID][]string{}
+
+	workers := StartOrderedWorkers(context.Background(), 4, func(e *Event) {
+		// The earlier events take longer, so they would be overtaken if they were handled concurrently.
+		count, _ := strconv.Atoi(e.Name)
+		time.Sleep(time.Duration(10-count) * time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		handled[e.InvolvedObject.UID] = append(handled[e.InvolvedObject.UID], e.Name)
+	})
+
+	want := map[types.UID][]string{}
+	for i := 0; i < 10; i++ {
+		for _, uid := range []types.UID{"pod-a", "pod-b", "pod-c"} {
+			workers.Dispatch(context.Background(), &Event{Event: &corev1.Event{
+				ObjectMeta:     metav1.ObjectMeta{Name: strconv.Itoa(i)},
+				InvolvedObject: corev1.ObjectReference{UID: uid},
+			}})
+			want[uid] = append(want[uid], strconv.Itoa(i))
+		}
+	}
+	workers.Stop()
+
+	if !reflect.DeepEqual(handled, want) {
+		t.Errorf("handled = %v, want %v", handled, want)
+	}
+}
+
+func TestOrderedWorkers_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	handling, release := make(chan struct{}), make(chan struct{})
+	workers := StartOrderedWorkers(ctx, 1, func(e *Event) {
+		close(handling)
+		<-release
+	})
+
+	for i := 0; i < 5; i++ {
+		if !workers.Dispatch(ctx, &Event{Event: &corev1.Event{InvolvedObject: corev1.ObjectReference{UID: "pod-a"}}}) {
+			t.Fatalf("event %v is dropped before canceling", i)
+		}
+	}
+	<-handling
+	cancel()
+	close(release)
+
+	if workers.Dispatch(ctx, &Event{Event: &corev1.Event{}}) {
+		t.Errorf("event is dispatched after canceling")
+	}
+	if dropped := workers.Stop(); dropped != 4 {
+		t.Errorf("dropped = %v, want 4", dropped)
+	}
+}
+
+func TestEvent_InvolvedObjectKey(t *testing.T) {
+	tests := []struct {
+		name string
+		e    *Event
+		want string
+	}{
+		{
+			name: "uid",
+			e:    &Event{Event: &corev1.Event{InvolvedObject: corev1.ObjectReference{UID: "uid", Kind: "Pod", Name: "reviews"}}},
+			want: "uid",
+		},
+		{
+			name: "no uid",
+			e: &Event{Cluster: "prod", Event: &corev1.Event{
+				InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "reviews"},
+			}},
+			want: "prod/Pod/default/reviews",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.e.InvolvedObjectKey(); got != tt.want {
+				t.Errorf("InvolvedObjectKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -22,177 +22,430 @@ package k8s
 import (
 	"context"
 	"fmt"
-
-	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
+	"sort"
+	"sync"
+	"time"
 
 	lru "github.com/hashicorp/golang-lru"
 	corev1 "k8s.io/api/core/v1"
-
-	"time"
-
-	"k8s.io/client-go/informers"
-	"k8s.io/client-go/kubernetes"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/cache"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
 )
 
-type id struct {
-	namespace string
-	name      string
+// contextTimeout bounds the time to resolve the template context of an event.
+const contextTimeout = time.Minute
+
+// RegistryOptions configures the registry of a cluster.
+type RegistryOptions struct {
+	// Kinds are the kinds of the involved objects to watch, in the form of `Kind.group`.
+	Kinds []string
+	// CacheSize is the maximum number of the objects fetched on demand, and the
+	// recently deleted objects, to cache respectively, 0 means 1000.
+	CacheSize int
+	// DeletedObjectTTL is how long the deleted objects are kept to enrich the events that
+	// are watched after the deletion, 0 disables keeping the deleted objects.
+	DeletedObjectTTL time.Duration
+	// KeepFields are the fields of the Pods, Services and Nodes to keep in the informers, like PodSpec,
+	// the other fields are dropped except the metadata and the ones that the registry needs.
+	KeepFields []string
 }
 
-func (i id) String() string {
-	return fmt.Sprintf("%+v:%+v", i.namespace, i.name)
+type deletedObject struct {
+	object interface{}
+	expiry time.Time
+}
+
+// watchedKinds are the kinds of the objects in the template context, and the indexers of their stores.
+var watchedKinds = map[string]cache.Indexers{
+	"Pod":       {},
+	"Service":   {cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+	"Endpoints": {podIndex: endpointsPods},
+	"Node":      {},
 }
 
 type registry struct {
-	informers []cache.SharedIndexInformer
-
-	podIDIpMap *lru.Cache // map[id]string
-	idSvcMap   *lru.Cache // map[id]*corev1.Service
-	idPodMap   *lru.Cache // map[id]*corev1.Pod
-	ipSvcIDMap *lru.Cache // map[string]id
-}
-
-func (r registry) OnAdd(obj interface{}) {
-	switch o := obj.(type) {
-	case *corev1.Pod:
-		podID := id{namespace: o.Namespace, name: o.Name}.String()
-
-		r.podIDIpMap.Add(podID, o.Status.PodIP)
-		r.idPodMap.Add(podID, o)
-	case *corev1.Service:
-		svcID := id{namespace: o.Namespace, name: o.Name}.String()
-
-		r.idSvcMap.Add(svcID, o)
-	case *corev1.Endpoints:
-		for _, subset := range o.Subsets {
-			for _, address := range subset.Addresses {
-				svcID := id{namespace: o.ObjectMeta.Namespace, name: o.ObjectMeta.Name}.String()
-				r.ipSvcIDMap.Add(address.IP, svcID)
+	informers []cache.SharedIndexInformer // the informers to transform, they are started by the SharedInformers
+	// static registry serves the objects that are added manually rather than watched from
+	// the cluster, so there is no need to wait for the missing objects to appear.
+	static bool
+
+	stores   map[string]cache.Indexer        // keyed by the kind
+	synced   map[string]cache.InformerSynced // keyed by the kind, empty for static registry
+	notifier *notifier
+	options  RegistryOptions
+
+	idObjectMap  *lru.Cache // map[objectKey]*cachedObject
+	keyDeleteMap *lru.Cache // map[kind/key]*deletedObject
+
+	workloadListers map[string]cache.GenericLister // keyed by the workload kind
+	dynamicClient   dynamic.Interface
+	restMapper      meta.RESTMapper
+}
+
+type TemplateContext struct {
+	Cluster string
+	// Service is the primary one of Services, i.e. the first one sorted by the name.
+	Service  *corev1.Service
+	Services []*corev1.Service
+	Pod      *corev1.Pod
+	Workload *Workload
+	Node     *corev1.Node
+	Object   *Object
+	Event    *corev1.Event
+}
+
+func emptyContext(e *Event) TemplateContext {
+	return TemplateContext{
+		Cluster:  e.Cluster,
+		Event:    e.Event,
+		Pod:      &corev1.Pod{},
+		Workload: &Workload{},
+		Node:     &corev1.Node{},
+		Object:   &Object{Object: map[string]interface{}{}},
+		Service:  &corev1.Service{},
+		Services: []*corev1.Service{},
+	}
+}
+
+// GetContext resolves the template context of the event, the objects are served from the informer
+// stores as soon as they exist, and the missing ones are left empty with the reason logged.
+func (r *registry) GetContext(ctx context.Context, e *Event) chan TemplateContext {
+	resultCh := make(chan TemplateContext, 1)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, contextTimeout)
+		defer cancel()
+
+		result := emptyContext(e)
+		if err := r.resolve(ctx, e, &result); err != nil {
+			logger.Log.Debugf("template context of event %v/%v is incomplete. %+v", e.Namespace, e.Name, err)
+		}
+		resultCh <- result
+	}()
+
+	return resultCh
+}
+
+func (r *registry) resolve(ctx context.Context, e *Event, result *TemplateContext) error {
+	obj := e.InvolvedObject
+	key := obj.Name
+	if obj.Namespace != "" {
+		key = obj.Namespace + "/" + obj.Name
+	}
+
+	switch obj.Kind {
+	case "Pod":
+		pod, err := r.waitFor(ctx, "Pod", key)
+		if err != nil {
+			return err
+		}
+		result.Pod = pod.(*corev1.Pod)
+		result.Object = typedObject(result.Pod)
+
+		if workload := r.resolveWorkload(obj.Namespace, metav1.GetControllerOfNoCopy(result.Pod)); workload != nil {
+			result.Workload = workload
+		}
+
+		if nodeName := result.Pod.Spec.NodeName; nodeName != "" {
+			if node, err := r.waitFor(ctx, "Node", nodeName); err != nil {
+				logger.Log.Debugf("node of pod %v is not resolved. %+v", key, err)
+			} else {
+				result.Node = node.(*corev1.Node)
 			}
 		}
+
+		services, err := r.servicesOf(ctx, result.Pod)
+		if err != nil {
+			return err
+		}
+		if len(services) > 0 {
+			result.Service = services[0]
+			result.Services = services
+		}
+	case "Service":
+		svc, err := r.waitFor(ctx, "Service", key)
+		if err != nil {
+			return err
+		}
+		result.Service = svc.(*corev1.Service)
+		result.Services = []*corev1.Service{result.Service}
+		result.Object = typedObject(result.Service)
+	case "Node":
+		node, err := r.waitFor(ctx, "Node", key)
+		if err != nil {
+			return err
+		}
+		result.Node = node.(*corev1.Node)
+		result.Object = typedObject(result.Node)
+	default:
+		if _, ok := workloadResources[obj.Kind]; ok {
+			ref := &metav1.OwnerReference{APIVersion: obj.APIVersion, Kind: obj.Kind, Name: obj.Name}
+			if workload := r.resolveWorkload(obj.Namespace, ref); workload != nil {
+				result.Workload = workload
+			}
+		}
+
+		object, err := r.getObject(ctx, &obj)
+		if err != nil {
+			return err
+		}
+		result.Object = object
 	}
+
+	return nil
 }
 
-func (r registry) OnUpdate(oldObj, newObj interface{}) {
-	r.OnDelete(oldObj)
-	r.OnAdd(newObj)
+// servicesOf returns the Services that the pod belongs to, sorted by the name, i.e. the Services whose selectors
+// match the labels of the pod, and the Services without selectors whose EndpointSlices or Endpoints refer to the pod.
+func (r *registry) servicesOf(ctx context.Context, pod *corev1.Pod) ([]*corev1.Service, error) {
+	if err := r.waitForSync(ctx, "Service"); err != nil {
+		return nil, err
+	}
+	if err := r.waitForSync(ctx, "Endpoints"); err != nil {
+		return nil, err
+	}
+
+	objs, err := r.stores["Service"].ByIndex(cache.NamespaceIndex, pod.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	var services []*corev1.Service
+	found := map[string]bool{}
+	for _, obj := range objs {
+		svc := obj.(*corev1.Service)
+		if len(svc.Spec.Selector) > 0 && labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+			services = append(services, svc)
+			found[svc.Name] = true
+		}
+	}
+
+	endpoints, err := r.stores["Endpoints"].ByIndex(podIndex, pod.Namespace+"/"+pod.Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range endpoints {
+		name := serviceNameOf(obj)
+		if name == "" || found[name] {
+			continue
+		}
+		if svc, exists, err := r.stores["Service"].GetByKey(pod.Namespace + "/" + name); err == nil && exists {
+			services = append(services, svc.(*corev1.Service))
+			found[name] = true
+		}
+	}
+
+	if len(services) == 0 {
+		return nil, fmt.Errorf("pod %v/%v doesn't belong to any service", pod.Namespace, pod.Name)
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Name < services[j].Name
+	})
+
+	return services, nil
 }
 
-func (r registry) OnDelete(_ interface{}) {
+// registries holds the template context registries of all the clusters, keyed by the cluster name.
+type registries struct {
+	sync.RWMutex
+	m map[string]*registry
 }
 
-func (r *registry) Start(ctx context.Context) {
-	logger.Log.Debugf("starting registry")
+var Registry = &registries{m: map[string]*registry{}}
 
-	for _, informer := range r.informers {
-		go informer.Run(ctx.Done())
-	}
+func (rs *registries) add(cluster string, r *registry) {
+	rs.Lock()
+	defer rs.Unlock()
+
+	rs.m[cluster] = r
 }
 
-type TemplateContext struct {
-	Service *corev1.Service
-	Pod     *corev1.Pod
-	Event   *corev1.Event
+func (rs *registries) get(cluster string) *registry {
+	rs.RLock()
+	defer rs.RUnlock()
+
+	return rs.m[cluster]
 }
 
-func (r *registry) GetContext(ctx context.Context, e *corev1.Event) chan TemplateContext {
-	resultCh := make(chan TemplateContext)
+// GetContext returns the template context of the event from the registry of
+// the cluster where the event happens.
+func (rs *registries) GetContext(ctx context.Context, e *Event) chan TemplateContext {
+	if r := rs.get(e.Cluster); r != nil {
+		return r.GetContext(ctx, e)
+	}
+
+	logger.Log.Debugf("registry of cluster %+v is not found, using empty template context", e.Cluster)
 
-	result := TemplateContext{
-		Event:   e,
-		Pod:     &corev1.Pod{},
-		Service: &corev1.Service{},
+	resultCh := make(chan TemplateContext, 1)
+	resultCh <- emptyContext(e)
+	return resultCh
+}
+
+// AddStatic registers a static registry of the cluster that serves the given objects, without
+// watching the cluster, it's typically used to replay the recorded events with fixtures.
+func (rs *registries) AddStatic(cluster string, objects []runtime.Object) error {
+	r := &registry{static: true, stores: map[string]cache.Indexer{}, notifier: newNotifier()}
+	if err := r.initCaches(); err != nil {
+		return err
 	}
 
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				resultCh <- result
-				return
-			default:
-				if obj := e.InvolvedObject; obj.Kind == "Pod" {
-					podID := id{namespace: obj.Namespace, name: obj.Name}.String()
-
-					pod, ok := r.idPodMap.Get(podID)
-					if !ok {
-						break
-					}
-					result.Pod = pod.(*corev1.Pod)
-
-					podIP, ok := r.podIDIpMap.Get(podID)
-					if !ok {
-						break
-					}
-
-					svcID, ok := r.ipSvcIDMap.Get(podIP.(string))
-					if !ok {
-						break
-					}
-
-					svc, ok := r.idSvcMap.Get(svcID.(string))
-					if !ok {
-						break
-					}
-					result.Service = svc.(*corev1.Service)
-				} else if obj.Kind == "Service" {
-					svcID := id{namespace: obj.Namespace, name: obj.Name}.String()
-
-					svc, ok := r.idSvcMap.Get(svcID)
-					if !ok {
-						break
-					}
-					result.Service = svc.(*corev1.Service)
-				}
-
-				resultCh <- result
+	for kind, indexers := range watchedKinds {
+		r.stores[kind] = cache.NewIndexer(cache.MetaNamespaceKeyFunc, indexers)
+	}
+	for _, obj := range objects {
+		r.addStaticObject(obj)
 
-				return
+		kinds, _, err := scheme.Scheme.ObjectKinds(obj)
+		if err != nil || len(kinds) == 0 {
+			continue
+		}
+		kind := kinds[0].Kind
+		if kind == "EndpointSlice" {
+			kind = "Endpoints"
+		}
+		if store := r.stores[kind]; store != nil {
+			if err := store.Add(obj); err != nil {
+				return err
 			}
-			time.Sleep(3 * time.Second)
 		}
-	}()
+	}
+	r.workloadListers = staticWorkloadListers(objects)
 
-	return resultCh
-}
+	rs.add(cluster, r)
 
-var Registry = &registry{}
+	return nil
+}
 
-func (r *registry) Init() error {
+// Init initializes the registry that watches the cluster with the shared informers,
+// the objects of the kinds in the options are watched so that they are always served from the informers.
+func (r *registry) Init(shared *SharedInformers, options RegistryOptions) error {
 	logger.Log.Debugf("initializing template context registry")
 
-	var err error
-
-	if Registry.podIDIpMap, err = lru.New(1000); err != nil {
+	r.options = options
+	if err := r.initCaches(); err != nil {
 		return err
 	}
-	if Registry.idSvcMap, err = lru.New(1000); err != nil {
+
+	endpoints, err := endpointsInformer(shared.Factory, shared.Discovery)
+	if err != nil {
 		return err
 	}
-	if Registry.idPodMap, err = lru.New(1000); err != nil {
-		return err
+
+	r.stores = map[string]cache.Indexer{}
+	r.synced = map[string]cache.InformerSynced{}
+	r.notifier = newNotifier()
+	for kind, informer := range map[string]cache.SharedIndexInformer{
+		"Pod":       shared.Factory.Core().V1().Pods().Informer(),
+		"Service":   shared.Factory.Core().V1().Services().Informer(),
+		"Endpoints": endpoints,
+		"Node":      shared.Factory.Core().V1().Nodes().Informer(),
+	} {
+		// The informers of the factory have the namespace index already.
+		indexers := cache.Indexers{}
+		for name, indexFunc := range watchedKinds[kind] {
+			if _, ok := informer.GetIndexer().GetIndexers()[name]; !ok {
+				indexers[name] = indexFunc
+			}
+		}
+		if err := informer.AddIndexers(indexers); err != nil {
+			return err
+		}
+		informer.AddEventHandler(r.handler(kind))
+
+		r.informers = append(r.informers, informer)
+		r.stores[kind] = informer.GetIndexer()
+		r.synced[kind] = informer.HasSynced
 	}
-	if Registry.ipSvcIDMap, err = lru.New(1000); err != nil {
-		return err
+
+	workloadInformers, workloadListers := initWorkloadInformers(shared)
+	for _, informer := range workloadInformers {
+		r.informers = append(r.informers, informer)
 	}
+	r.workloadListers = workloadListers
 
-	config, err := GetConfig()
-	if err != nil {
+	// The objects of the watched kinds are served like the ones of the template context.
+	for groupKind, informer := range initObjectInformers(shared, options.Kinds) {
+		kind := objectStoreKind(groupKind)
+		informer.AddEventHandler(r.handler(kind))
+
+		r.informers = append(r.informers, informer)
+		r.stores[kind] = informer.GetIndexer()
+		r.synced[kind] = informer.HasSynced
+	}
+	r.dynamicClient = shared.Dynamic
+	r.restMapper = shared.Mapper
+
+	transformFunc := transform(options.KeepFields)
+	for _, informer := range r.informers {
+		if err := informer.SetTransform(transformFunc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *registry) initCaches() (err error) {
+	size := r.options.CacheSize
+	if size <= 0 {
+		size = 1000
+	}
+	if r.idObjectMap, err = lru.New(size); err != nil {
+		return err
+	}
+	if r.keyDeleteMap, err = lru.New(size); err != nil {
 		return err
 	}
-	client := kubernetes.NewForConfigOrDie(config)
-	factory := informers.NewSharedInformerFactoryWithOptions(client, 0, informers.WithNamespace(corev1.NamespaceAll))
+	return nil
+}
 
-	r.informers = []cache.SharedIndexInformer{
-		factory.Core().V1().Endpoints().Informer(),
-		factory.Core().V1().Services().Informer(),
-		factory.Core().V1().Pods().Informer(),
+// handler returns the event handler of the informer of the given kind, it notifies the waiters
+// of the added objects, and keeps the deleted objects for a while if it's enabled.
+func (r *registry) handler(kind string) cache.ResourceEventHandler {
+	notify := func(obj interface{}) {
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err != nil {
+			return
+		}
+		r.keyDeleteMap.Remove(kind + "/" + key)
+		r.notifier.notify(kind, key)
 	}
 
-	for _, informer := range Registry.informers {
-		informer.AddEventHandler(Registry)
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    notify,
+		UpdateFunc: func(_, newObj interface{}) { notify(newObj) },
+		DeleteFunc: func(obj interface{}) {
+			if r.options.DeletedObjectTTL <= 0 {
+				return
+			}
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			key, err := cache.MetaNamespaceKeyFunc(obj)
+			if err != nil {
+				return
+			}
+			r.keyDeleteMap.Add(kind+"/"+key, &deletedObject{object: obj, expiry: time.Now().Add(r.options.DeletedObjectTTL)})
+		},
 	}
+}
 
-	return nil
+// recentlyDeleted returns the object of the given kind and key if it's deleted within the DeletedObjectTTL.
+func (r *registry) recentlyDeleted(kind, key string) (interface{}, bool) {
+	cached, ok := r.keyDeleteMap.Get(kind + "/" + key)
+	if !ok {
+		return nil, false
+	}
+	if deleted := cached.(*deletedObject); time.Now().Before(deleted.expiry) {
+		return deleted.object, true
+	}
+	r.keyDeleteMap.Remove(kind + "/" + key)
+	return nil, false
 }
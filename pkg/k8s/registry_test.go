@@ -0,0 +1,256 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package k8s
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestRegistry_services(t *testing.T) {
+	pod := func(name, ip string, hostNetwork bool, labels map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Labels: labels},
+			Spec:       corev1.PodSpec{HostNetwork: hostNetwork},
+			Status:     corev1.PodStatus{PodIP: ip},
+		}
+	}
+	service := func(namespace, name string, selector map[string]string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       corev1.ServiceSpec{Selector: selector},
+		}
+	}
+	endpoints := func(name string, ready, notReady map[string]string) *corev1.Endpoints {
+		addresses := func(pods map[string]string) (addresses []corev1.EndpointAddress) {
+			for podName, ip := range pods {
+				addresses = append(addresses, corev1.EndpointAddress{
+					IP:        ip,
+					TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: podName},
+				})
+			}
+			return addresses
+		}
+		return &corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Subsets:    []corev1.EndpointSubset{{Addresses: addresses(ready), NotReadyAddresses: addresses(notReady)}},
+		}
+	}
+	endpointSlice := func(name, service string, addressType discoveryv1.AddressType, pods map[string]string) *discoveryv1.EndpointSlice {
+		slice := &discoveryv1.EndpointSlice{
+			ObjectMeta:  metav1.ObjectMeta{Namespace: "default", Name: name, Labels: map[string]string{discoveryv1.LabelServiceName: service}},
+			AddressType: addressType,
+		}
+		for podName, ip := range pods {
+			slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{
+				Addresses: []string{ip},
+				TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: podName},
+			})
+		}
+		return slice
+	}
+
+	objects := []runtime.Object{
+		pod("reviews-v1", "10.0.0.1", false, map[string]string{"app": "reviews", "version": "v1"}),
+		pod("reviews-v2", "10.0.0.2", false, map[string]string{"app": "reviews", "version": "v2"}),
+		pod("node-exporter", "192.168.0.1", true, map[string]string{"app": "node-exporter"}),
+		pod("ingress", "192.168.0.1", true, map[string]string{"app": "ingress"}),
+		pod("mysql-0", "10.0.0.3", false, map[string]string{"app": "mysql"}),
+		pod("details", "10.0.0.4", false, map[string]string{"app": "details"}),
+		pod("job", "", false, nil),
+		pod("redis-0", "10.0.0.5", false, nil),
+		pod("redis-1", "10.0.0.6", false, nil),
+		service("default", "reviews", map[string]string{"app": "reviews"}),
+		service("default", "reviews-v1", map[string]string{"app": "reviews", "version": "v1"}),
+		service("default", "node-exporter", map[string]string{"app": "node-exporter"}),
+		service("default", "mysql-external", nil),
+		service("default", "details-old", nil),
+		service("other", "details", map[string]string{"app": "details"}),
+		service("default", "redis", nil),
+		endpoints("reviews", map[string]string{"reviews-v1": "10.0.0.1"}, map[string]string{"reviews-v2": "10.0.0.2"}),
+		endpoints("node-exporter", map[string]string{"node-exporter": "192.168.0.1"}, nil),
+		endpoints("mysql-external", map[string]string{"mysql-0": "10.0.0.3"}, nil),
+		// The Endpoints of a deleted pod whose IP is reused by the details pod.
+		endpoints("details-old", map[string]string{"details-deleted": "10.0.0.4"}, nil),
+		// A dual-stack Service whose endpoints are split into several EndpointSlices.
+		endpointSlice("redis-ipv4-a", "redis", discoveryv1.AddressTypeIPv4, map[string]string{"redis-0": "10.0.0.5"}),
+		endpointSlice("redis-ipv6-a", "redis", discoveryv1.AddressTypeIPv6, map[string]string{"redis-0": "fd00::5"}),
+		endpointSlice("redis-ipv4-b", "redis", discoveryv1.AddressTypeIPv4, map[string]string{"redis-1": "10.0.0.6"}),
+	}
+	if err := Registry.AddStatic("services", objects); err != nil {
+		t.Fatalf("AddStatic() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		pod      string
+		want     []string
+		wantMain string
+	}{
+		{name: "multiple services sorted by name", pod: "reviews-v1", want: []string{"reviews", "reviews-v1"}, wantMain: "reviews"},
+		{name: "pod not ready", pod: "reviews-v2", want: []string{"reviews"}, wantMain: "reviews"},
+		{name: "host network pod", pod: "node-exporter", want: []string{"node-exporter"}, wantMain: "node-exporter"},
+		{name: "host network pod sharing the IP", pod: "ingress", want: []string{}},
+		{name: "service without selector", pod: "mysql-0", want: []string{"mysql-external"}, wantMain: "mysql-external"},
+		{name: "reused IP and service in another namespace", pod: "details", want: []string{}},
+		{name: "EndpointSlices of dual-stack service", pod: "redis-0", want: []string{"redis"}, wantMain: "redis"},
+		{name: "multiple EndpointSlices of service", pod: "redis-1", want: []string{"redis"}, wantMain: "redis"},
+		{name: "pod without IP", pod: "job", want: []string{}},
+		{name: "pod not found", pod: "missing", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Event{
+				Cluster: "services",
+				Event: &corev1.Event{
+					InvolvedObject: corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: "default", Name: tt.pod},
+				},
+			}
+			c := <-Registry.GetContext(context.Background(), e)
+
+			got := []string{}
+			for _, svc := range c.Services {
+				got = append(got, svc.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Services = %v, want %v", got, tt.want)
+			}
+			if c.Service.Name != tt.wantMain {
+				t.Errorf("Service = %v, want %v", c.Service.Name, tt.wantMain)
+			}
+		})
+	}
+}
+
+func TestRegistry_nodes(t *testing.T) {
+	zone := map[string]string{"topology.kubernetes.io/zone": "us-east-1a"}
+	objects := []runtime.Object{
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: zone}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "reviews-v1"}, Spec: corev1.PodSpec{NodeName: "node-1"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pending"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "evicted"}, Spec: corev1.PodSpec{NodeName: "node-deleted"}},
+	}
+	if err := Registry.AddStatic("nodes", objects); err != nil {
+		t.Fatalf("AddStatic() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		object     corev1.ObjectReference
+		wantNode   string
+		wantLabels map[string]string
+	}{
+		{
+			name:       "node of pod",
+			object:     corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: "default", Name: "reviews-v1"},
+			wantNode:   "node-1",
+			wantLabels: zone,
+		},
+		{
+			name:       "node itself",
+			object:     corev1.ObjectReference{APIVersion: "v1", Kind: "Node", Name: "node-1"},
+			wantNode:   "node-1",
+			wantLabels: zone,
+		},
+		{
+			name:   "pod not scheduled",
+			object: corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: "default", Name: "pending"},
+		},
+		{
+			name:   "node of pod not found",
+			object: corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: "default", Name: "evicted"},
+		},
+		{
+			name:   "node not found",
+			object: corev1.ObjectReference{APIVersion: "v1", Kind: "Node", Name: "node-deleted"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := <-Registry.GetContext(context.Background(), &Event{Cluster: "nodes", Event: &corev1.Event{InvolvedObject: tt.object}})
+
+			if c.Node.Name != tt.wantNode {
+				t.Errorf("Node = %v, want %v", c.Node.Name, tt.wantNode)
+			}
+			if len(tt.wantLabels) > 0 && !reflect.DeepEqual(c.Node.Labels, tt.wantLabels) {
+				t.Errorf("Node.Labels = %v, want %v", c.Node.Labels, tt.wantLabels)
+			}
+		})
+	}
+}
+
+func TestRegistry_deletedObjects(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "reviews-v1"}}
+
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		tombstone bool
+		wantFound bool
+	}{
+		{name: "deleted object is kept", ttl: time.Minute, wantFound: true},
+		{name: "tombstone is kept", ttl: time.Minute, tombstone: true, wantFound: true},
+		{name: "deleted object is expired", ttl: time.Nanosecond, wantFound: false},
+		{name: "keeping deleted objects is disabled", wantFound: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &registry{
+				static:   true,
+				stores:   map[string]cache.Indexer{"Pod": cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})},
+				notifier: newNotifier(),
+				options:  RegistryOptions{DeletedObjectTTL: tt.ttl},
+			}
+			if err := r.initCaches(); err != nil {
+				t.Fatalf("initCaches() error = %v", err)
+			}
+			handler := r.handler("Pod")
+
+			handler.OnAdd(pod)
+			var deleted interface{} = pod
+			if tt.tombstone {
+				deleted = cache.DeletedFinalStateUnknown{Key: "default/reviews-v1", Obj: pod}
+			}
+			handler.OnDelete(deleted)
+			time.Sleep(time.Millisecond)
+
+			obj, err := r.waitFor(context.Background(), "Pod", "default/reviews-v1")
+			if found := err == nil; found != tt.wantFound {
+				t.Fatalf("waitFor() error = %v, wantFound %v", err, tt.wantFound)
+			}
+			if tt.wantFound && obj != pod {
+				t.Errorf("waitFor() = %v, want %v", obj, pod)
+			}
+
+			handler.OnAdd(pod)
+			if _, ok := r.recentlyDeleted("Pod", "default/reviews-v1"); ok {
+				t.Errorf("recentlyDeleted() of the added object = true, want false")
+			}
+		})
+	}
+}
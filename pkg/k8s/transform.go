@@ -0,0 +1,96 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package k8s
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Fields of the objects that can be kept in the informers by RegistryOptions.KeepFields,
+// the metadata and the fields that the registry needs are always kept.
+const (
+	PodSpec       = "Pod.Spec"
+	PodStatus     = "Pod.Status"
+	ServiceSpec   = "Service.Spec"
+	ServiceStatus = "Service.Status"
+	NodeSpec      = "Node.Spec"
+	NodeStatus    = "Node.Status"
+)
+
+// transform returns the transform function of the informers, which drops the managed fields of all the objects,
+// and drops the fields of the Pods, Services and Nodes that are not kept, to reduce the memory of the informers.
+func transform(keepFields []string) cache.TransformFunc {
+	keep := map[string]bool{}
+	for _, field := range keepFields {
+		keep[field] = true
+	}
+
+	return func(obj interface{}) (interface{}, error) {
+		if accessor, err := meta.Accessor(obj); err == nil {
+			accessor.SetManagedFields(nil)
+		}
+
+		switch o := obj.(type) {
+		case *corev1.Pod:
+			if !keep[PodSpec] {
+				o.Spec = corev1.PodSpec{
+					NodeName:           o.Spec.NodeName,
+					HostNetwork:        o.Spec.HostNetwork,
+					ServiceAccountName: o.Spec.ServiceAccountName,
+				}
+			}
+			if !keep[PodStatus] {
+				o.Status = corev1.PodStatus{
+					Phase:  o.Status.Phase,
+					HostIP: o.Status.HostIP,
+					PodIP:  o.Status.PodIP,
+					PodIPs: o.Status.PodIPs,
+				}
+			}
+		case *corev1.Service:
+			if !keep[ServiceSpec] {
+				o.Spec = corev1.ServiceSpec{
+					Type:       o.Spec.Type,
+					Selector:   o.Spec.Selector,
+					ClusterIP:  o.Spec.ClusterIP,
+					ClusterIPs: o.Spec.ClusterIPs,
+				}
+			}
+			if !keep[ServiceStatus] {
+				o.Status = corev1.ServiceStatus{}
+			}
+		case *corev1.Node:
+			if !keep[NodeSpec] {
+				o.Spec = corev1.NodeSpec{
+					ProviderID: o.Spec.ProviderID,
+				}
+			}
+			if !keep[NodeStatus] {
+				o.Status = corev1.NodeStatus{
+					Addresses: o.Status.Addresses,
+				}
+			}
+		}
+
+		return obj, nil
+	}
+}
@@ -0,0 +1,76 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package k8s
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTransform(t *testing.T) {
+	newPod := func() *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:          "reviews-v1",
+				Labels:        map[string]string{"app": "reviews"},
+				ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubelet"}},
+			},
+			Spec: corev1.PodSpec{
+				NodeName:   "node-1",
+				Containers: []corev1.Container{{Name: "reviews", Image: "reviews:v1"}},
+			},
+			Status: corev1.PodStatus{
+				Phase:      corev1.PodRunning,
+				PodIP:      "10.0.0.1",
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady}},
+			},
+		}
+	}
+
+	t.Run("drop fields", func(t *testing.T) {
+		obj, err := transform(nil)(newPod())
+		if err != nil {
+			t.Fatalf("transform() error = %v", err)
+		}
+		want := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "reviews-v1", Labels: map[string]string{"app": "reviews"}},
+			Spec:       corev1.PodSpec{NodeName: "node-1"},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.0.0.1"},
+		}
+		if !reflect.DeepEqual(obj, want) {
+			t.Errorf("transform() = %+v, want %+v", obj, want)
+		}
+	})
+
+	t.Run("keep fields", func(t *testing.T) {
+		obj, err := transform([]string{PodSpec, PodStatus})(newPod())
+		if err != nil {
+			t.Fatalf("transform() error = %v", err)
+		}
+		want := newPod()
+		want.ManagedFields = nil
+		if !reflect.DeepEqual(obj, want) {
+			t.Errorf("transform() = %+v, want %+v", obj, want)
+		}
+	})
+}
@@ -0,0 +1,138 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// objectAppearTimeout is how long to wait for the missing object after the cache is synced,
+// because an event can be watched a little earlier than the object it involves.
+var objectAppearTimeout = 3 * time.Second
+
+// notifier notifies the waiters when the objects they wait for are added or updated.
+type notifier struct {
+	sync.Mutex
+	waiters map[string][]chan struct{} // keyed by kind/key
+}
+
+func newNotifier() *notifier {
+	return &notifier{waiters: map[string][]chan struct{}{}}
+}
+
+// wait registers a waiter of the object with the given key, the returned channel is closed
+// when the object is added or updated, cancel must be called to unregister the waiter.
+func (n *notifier) wait(kind, key string) (appeared <-chan struct{}, cancel func()) {
+	n.Lock()
+	defer n.Unlock()
+
+	ch := make(chan struct{})
+	id := kind + "/" + key
+	n.waiters[id] = append(n.waiters[id], ch)
+
+	return ch, func() {
+		n.Lock()
+		defer n.Unlock()
+
+		waiters := n.waiters[id]
+		for i, waiter := range waiters {
+			if waiter == ch {
+				waiters = append(waiters[:i], waiters[i+1:]...)
+				break
+			}
+		}
+		if len(waiters) == 0 {
+			delete(n.waiters, id)
+		} else {
+			n.waiters[id] = waiters
+		}
+	}
+}
+
+func (n *notifier) notify(kind, key string) {
+	n.Lock()
+	defer n.Unlock()
+
+	id := kind + "/" + key
+	for _, ch := range n.waiters[id] {
+		close(ch)
+	}
+	delete(n.waiters, id)
+}
+
+// waitForSync waits for the cache of the given kind to be synced.
+func (r *registry) waitForSync(ctx context.Context, kind string) error {
+	synced := r.synced[kind]
+	if synced == nil || synced() {
+		return nil
+	}
+	if err := wait.PollImmediateUntil(100*time.Millisecond, func() (bool, error) {
+		return synced(), nil
+	}, ctx.Done()); err != nil {
+		return fmt.Errorf("cache of %v is not synced: %w", kind, err)
+	}
+	return nil
+}
+
+// waitFor returns the object of the given kind and key from the informer store, it waits for the
+// cache to be synced, and then waits for the object to appear for at most objectAppearTimeout.
+func (r *registry) waitFor(ctx context.Context, kind, key string) (interface{}, error) {
+	store, ok := r.stores[kind]
+	if !ok {
+		return nil, fmt.Errorf("%v is not watched", kind)
+	}
+
+	appeared, cancel := r.notifier.wait(kind, key)
+	defer cancel()
+
+	if err := r.waitForSync(ctx, kind); err != nil {
+		return nil, err
+	}
+
+	if obj, exists, err := store.GetByKey(key); err != nil || exists {
+		return obj, err
+	}
+	if obj, ok := r.recentlyDeleted(kind, key); ok {
+		return obj, nil
+	}
+	if r.static {
+		return nil, fmt.Errorf("%v %v is not found", kind, key)
+	}
+
+	timer := time.NewTimer(objectAppearTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-appeared:
+		if obj, exists, err := store.GetByKey(key); err != nil || exists {
+			return obj, err
+		}
+		return nil, fmt.Errorf("%v %v is not found", kind, key)
+	case <-timer.C:
+		return nil, fmt.Errorf("%v %v is not found in the synced cache", kind, key)
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%v %v is not found: %w", kind, key, ctx.Err())
+	}
+}
@@ -0,0 +1,187 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package k8s
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestNotifier(t *testing.T) {
+	n := newNotifier()
+	appeared1, cancel1 := n.wait("Pod", "default/reviews")
+	appeared2, cancel2 := n.wait("Pod", "default/reviews")
+	other, cancelOther := n.wait("Pod", "default/details")
+	defer cancel1()
+	defer cancel2()
+
+	n.notify("Pod", "default/reviews")
+	for i, appeared := range []<-chan struct{}{appeared1, appeared2} {
+		select {
+		case <-appeared:
+		default:
+			t.Errorf("waiter %v is not notified", i)
+		}
+	}
+	select {
+	case <-other:
+		t.Errorf("waiter of another object is notified")
+	default:
+	}
+
+	cancelOther()
+	if len(n.waiters) != 0 {
+		t.Errorf("waiters = %v, want none after they are notified or canceled", n.waiters)
+	}
+}
+
+// newWaitingRegistry returns the registry watching Pods, whose cache is synced once synced is true.
+func newWaitingRegistry(t *testing.T, synced *int32) *registry {
+	r := &registry{
+		stores:   map[string]cache.Indexer{"Pod": cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})},
+		synced:   map[string]cache.InformerSynced{"Pod": func() bool { return atomic.LoadInt32(synced) == 1 }},
+		notifier: newNotifier(),
+	}
+	if err := r.initCaches(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRegistry_waitFor(t *testing.T) {
+	defer func(timeout time.Duration) {
+		objectAppearTimeout = timeout
+	}(objectAppearTimeout)
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "reviews"}}
+	// add adds the pod to the store after the delay, like the informer does.
+	add := func(r *registry, delay time.Duration) {
+		time.Sleep(delay)
+		_ = r.stores["Pod"].Add(pod)
+		r.handler("Pod").OnAdd(pod)
+	}
+
+	tests := []struct {
+		name          string
+		appearTimeout time.Duration
+		syncDelay     time.Duration
+		addDelay      time.Duration // negative means the pod never appears
+		static        bool
+		wantFound     bool
+		// maxElapsed is how long waitFor takes at most.
+		maxElapsed time.Duration
+	}{
+		{
+			name:          "object exists",
+			appearTimeout: time.Minute,
+			wantFound:     true,
+			maxElapsed:    100 * time.Millisecond,
+		},
+		{
+			name:          "object appears after the lookup starts",
+			appearTimeout: time.Minute,
+			addDelay:      100 * time.Millisecond,
+			wantFound:     true,
+			maxElapsed:    time.Second,
+		},
+		{
+			name:          "object is added before the cache is synced",
+			appearTimeout: time.Minute,
+			syncDelay:     200 * time.Millisecond,
+			addDelay:      100 * time.Millisecond,
+			wantFound:     true,
+			maxElapsed:    time.Second,
+		},
+		{
+			name:          "object doesn't appear in objectAppearTimeout",
+			appearTimeout: 200 * time.Millisecond,
+			addDelay:      -1,
+			maxElapsed:    time.Second,
+		},
+		{
+			name:          "static registry fails fast",
+			appearTimeout: time.Minute,
+			addDelay:      -1,
+			static:        true,
+			maxElapsed:    100 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objectAppearTimeout = tt.appearTimeout
+			var synced int32
+			r := newWaitingRegistry(t, &synced)
+			r.static = tt.static
+
+			if tt.syncDelay == 0 {
+				atomic.StoreInt32(&synced, 1)
+			} else {
+				go func() {
+					time.Sleep(tt.syncDelay)
+					atomic.StoreInt32(&synced, 1)
+				}()
+			}
+			if tt.addDelay == 0 {
+				add(r, 0)
+			} else if tt.addDelay > 0 {
+				go add(r, tt.addDelay)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			start := time.Now()
+			obj, err := r.waitFor(ctx, "Pod", "default/reviews")
+			elapsed := time.Since(start)
+
+			if found := err == nil; found != tt.wantFound {
+				t.Fatalf("waitFor() error = %v, wantFound %v", err, tt.wantFound)
+			}
+			if tt.wantFound && obj != pod {
+				t.Errorf("waitFor() = %v, want %v", obj, pod)
+			}
+			if elapsed > tt.maxElapsed {
+				t.Errorf("waitFor() takes %v, want at most %v", elapsed, tt.maxElapsed)
+			}
+			if !tt.wantFound && !tt.static && elapsed < tt.appearTimeout {
+				t.Errorf("waitFor() fails in %v, before objectAppearTimeout %v", elapsed, tt.appearTimeout)
+			}
+		})
+	}
+}
+
+func TestRegistry_waitFor_notSynced(t *testing.T) {
+	var synced int32
+	r := newWaitingRegistry(t, &synced)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := r.waitFor(ctx, "Pod", "default/reviews"); err == nil {
+		t.Errorf("waitFor() error = nil, want error because the cache is not synced")
+	}
+	if _, err := r.waitFor(context.Background(), "Service", "default/reviews"); err == nil {
+		t.Errorf("waitFor() error = nil, want error because Services are not watched")
+	}
+}
@@ -0,0 +1,178 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package k8s
+
+import (
+	"errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
+)
+
+// Workload is the controller that owns a pod, like Deployment, StatefulSet, DaemonSet, Job or CronJob,
+// only the type and object metadata are kept, i.e. `.Workload.Kind`, `.Workload.Name` and `.Workload.Labels`.
+type Workload = metav1.PartialObjectMetadata
+
+// workloadResources are the kinds of the workloads that can be resolved from the owner references.
+var workloadResources = map[string]schema.GroupVersionResource{
+	"ReplicaSet":  {Group: "apps", Version: "v1", Resource: "replicasets"},
+	"Deployment":  {Group: "apps", Version: "v1", Resource: "deployments"},
+	"StatefulSet": {Group: "apps", Version: "v1", Resource: "statefulsets"},
+	"DaemonSet":   {Group: "apps", Version: "v1", Resource: "daemonsets"},
+	"Job":         {Group: "batch", Version: "v1", Resource: "jobs"},
+	"CronJob":     {Group: "batch", Version: "v1", Resource: "cronjobs"},
+}
+
+// maxOwnerDepth limits the length of the owner reference chain to resolve,
+// the longest chain of the built-in workloads is Pod → Job → CronJob.
+const maxOwnerDepth = 5
+
+// initWorkloadInformers creates the metadata informers of the workloads, keyed by the kind.
+func initWorkloadInformers(shared *SharedInformers) (map[string]cache.SharedIndexInformer, map[string]cache.GenericLister) {
+	informers := map[string]cache.SharedIndexInformer{}
+	listers := map[string]cache.GenericLister{}
+	for kind, gvr := range workloadResources {
+		if !isServed(shared.Discovery, gvr) {
+			// CronJob is served in batch/v1beta1 before Kubernetes 1.21.
+			if kind != "CronJob" {
+				logger.Log.Warnf("%v is not served by the cluster, skip resolving it", gvr)
+				continue
+			}
+			gvr.Version = "v1beta1"
+		}
+
+		informer := shared.MetadataFactory.ForResource(gvr)
+		informers[kind] = informer.Informer()
+		listers[kind] = informer.Lister()
+	}
+
+	return informers, listers
+}
+
+// isServed returns whether the cluster serves the resource, the failures of the discovery are logged.
+func isServed(client discovery.DiscoveryInterface, gvr schema.GroupVersionResource) bool {
+	served, err := serves(client, gvr)
+	if err != nil {
+		logger.Log.Warnf("failed to discover %v. %+v", gvr, err)
+	}
+	return served
+}
+
+// serves returns whether the cluster serves the resource, the group version that is not served
+// is not an error, while the other failures of the discovery are.
+func serves(client discovery.DiscoveryInterface, gvr schema.GroupVersionResource) (bool, error) {
+	resources, err := client.ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+	if apierrors.IsNotFound(err) || errors.Is(err, memory.ErrCacheNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == gvr.Resource {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// staticWorkloadListers creates the listers of the workloads among the given objects.
+func staticWorkloadListers(objects []runtime.Object) map[string]cache.GenericLister {
+	indexers := map[string]cache.Indexer{}
+	for _, obj := range objects {
+		kinds, _, err := scheme.Scheme.ObjectKinds(obj)
+		if err != nil || len(kinds) == 0 {
+			continue
+		}
+		kind := kinds[0].Kind
+		if _, ok := workloadResources[kind]; !ok {
+			continue
+		}
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			continue
+		}
+
+		if indexers[kind] == nil {
+			indexers[kind] = cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+		}
+		workload := &Workload{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       accessor.GetNamespace(),
+				Name:            accessor.GetName(),
+				UID:             accessor.GetUID(),
+				Labels:          accessor.GetLabels(),
+				Annotations:     accessor.GetAnnotations(),
+				OwnerReferences: accessor.GetOwnerReferences(),
+			},
+		}
+		if err := indexers[kind].Add(workload); err != nil {
+			logger.Log.Warnf("failed to add workload %v/%v. %+v", accessor.GetNamespace(), accessor.GetName(), err)
+		}
+	}
+
+	listers := map[string]cache.GenericLister{}
+	for kind, indexer := range indexers {
+		listers[kind] = cache.NewGenericLister(indexer, workloadResources[kind].GroupResource())
+	}
+	return listers
+}
+
+// resolveWorkload follows the controller references from the given one, and returns the top-most
+// known workload, e.g. Deployment for Pod → ReplicaSet → Deployment, and CronJob for Pod → Job → CronJob.
+// It returns nil if the given reference is nil.
+func (r *registry) resolveWorkload(namespace string, ref *metav1.OwnerReference) *Workload {
+	var workload *Workload
+
+	for depth := 0; ref != nil && depth < maxOwnerDepth; depth++ {
+		owner := &Workload{
+			TypeMeta:   metav1.TypeMeta{APIVersion: ref.APIVersion, Kind: ref.Kind},
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: ref.Name},
+		}
+
+		lister, ok := r.workloadListers[ref.Kind]
+		if !ok {
+			// Unknown controllers like custom resources, only the kind and name are known.
+			return owner
+		}
+		obj, err := lister.ByNamespace(namespace).Get(ref.Name)
+		if err != nil {
+			logger.Log.Debugf("workload %v %v/%v is not found. %+v", ref.Kind, namespace, ref.Name, err)
+			return owner
+		}
+
+		if o, ok := obj.(*Workload); ok {
+			owner.ObjectMeta = *o.ObjectMeta.DeepCopy()
+		}
+		workload = owner
+		ref = metav1.GetControllerOfNoCopy(owner)
+	}
+
+	return workload
+}
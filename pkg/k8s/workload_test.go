@@ -0,0 +1,181 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package k8s
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/cached/memory"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/metadata/fake"
+	"k8s.io/client-go/metadata/metadatainformer"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+// controllerOf returns the controller reference to the workload of the given kind and name.
+func controllerOf(apiVersion, kind, name string) []metav1.OwnerReference {
+	controller := true
+	return []metav1.OwnerReference{{APIVersion: apiVersion, Kind: kind, Name: name, Controller: &controller}}
+}
+
+func TestRegistry_resolveWorkload(t *testing.T) {
+	objects := []runtime.Object{
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default", Name: "reviews-v1-545db77b95", OwnerReferences: controllerOf("apps/v1", "Deployment", "reviews-v1"),
+		}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default", Name: "reviews-v1", Labels: map[string]string{"app": "reviews"},
+		}},
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default", Name: "backup-27600000", OwnerReferences: controllerOf("batch/v1", "CronJob", "backup"),
+		}},
+		&batchv1.CronJob{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backup"}},
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default", Name: "orphan-7d9f8b6c5", OwnerReferences: controllerOf("apps/v1", "Deployment", "deleted"),
+		}},
+	}
+	// A chain of ReplicaSets longer than maxOwnerDepth.
+	for i := 0; i <= maxOwnerDepth+1; i++ {
+		objects = append(objects, &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default", Name: "chain-" + strconv.Itoa(i), OwnerReferences: controllerOf("apps/v1", "ReplicaSet", "chain-"+strconv.Itoa(i+1)),
+		}})
+	}
+	r := &registry{workloadListers: staticWorkloadListers(objects)}
+
+	tests := []struct {
+		name       string
+		ref        *metav1.OwnerReference
+		wantNil    bool
+		wantKind   string
+		wantName   string
+		wantLabels map[string]string
+	}{
+		{
+			name:       "Pod → ReplicaSet → Deployment",
+			ref:        &controllerOf("apps/v1", "ReplicaSet", "reviews-v1-545db77b95")[0],
+			wantKind:   "Deployment",
+			wantName:   "reviews-v1",
+			wantLabels: map[string]string{"app": "reviews"},
+		},
+		{
+			name:     "Pod → Job → CronJob",
+			ref:      &controllerOf("batch/v1", "Job", "backup-27600000")[0],
+			wantKind: "CronJob",
+			wantName: "backup",
+		},
+		{
+			name:     "missing or unsynced owner",
+			ref:      &controllerOf("apps/v1", "ReplicaSet", "missing")[0],
+			wantKind: "ReplicaSet",
+			wantName: "missing",
+		},
+		{
+			name:     "missing owner of owner",
+			ref:      &controllerOf("apps/v1", "ReplicaSet", "orphan-7d9f8b6c5")[0],
+			wantKind: "Deployment",
+			wantName: "deleted",
+		},
+		{
+			name:     "unknown controller",
+			ref:      &controllerOf("example.com/v1", "Database", "orders")[0],
+			wantKind: "Database",
+			wantName: "orders",
+		},
+		{
+			name:     "max depth",
+			ref:      &controllerOf("apps/v1", "ReplicaSet", "chain-0")[0],
+			wantKind: "ReplicaSet",
+			wantName: "chain-" + strconv.Itoa(maxOwnerDepth-1),
+		},
+		{
+			name:    "no controller",
+			wantNil: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workload := r.resolveWorkload("default", tt.ref)
+			if workload == nil {
+				if !tt.wantNil {
+					t.Fatalf("resolveWorkload() = nil")
+				}
+				return
+			}
+			if tt.wantNil {
+				t.Fatalf("resolveWorkload() = %v, want nil", workload)
+			}
+			if workload.Kind != tt.wantKind || workload.Name != tt.wantName || workload.Namespace != "default" {
+				t.Errorf("resolveWorkload() = %v %v/%v, want %v default/%v", workload.Kind, workload.Namespace, workload.Name, tt.wantKind, tt.wantName)
+			}
+			if len(tt.wantLabels) > 0 && workload.Labels["app"] != tt.wantLabels["app"] {
+				t.Errorf("Labels = %v, want %v", workload.Labels, tt.wantLabels)
+			}
+		})
+	}
+}
+
+// TestInitWorkloadInformers_cronJobV1beta1 checks that CronJobs are watched in batch/v1beta1
+// if the cluster doesn't serve batch/v1 CronJobs, like Kubernetes before 1.21.
+func TestInitWorkloadInformers_cronJobV1beta1(t *testing.T) {
+	discovery := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{Resources: []*metav1.APIResourceList{
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{{Name: "replicasets"}, {Name: "deployments"}}},
+		{GroupVersion: "batch/v1", APIResources: []metav1.APIResource{{Name: "jobs"}}},
+		{GroupVersion: "batch/v1beta1", APIResources: []metav1.APIResource{{Name: "cronjobs"}}},
+	}}}
+
+	scheme := fake.NewTestScheme()
+	if err := metav1.AddMetaToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	client := fake.NewSimpleMetadataClient(scheme, &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "batch/v1beta1", Kind: "CronJob"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backup"},
+	})
+	factory := metadatainformer.NewSharedInformerFactory(client, 0)
+
+	informers, listers := initWorkloadInformers(&SharedInformers{Discovery: memory.NewMemCacheClient(discovery), MetadataFactory: factory})
+	for _, kind := range []string{"StatefulSet", "DaemonSet"} {
+		if _, ok := informers[kind]; ok {
+			t.Errorf("%v is watched although it's not served", kind)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informers["CronJob"].HasSynced) {
+		t.Fatalf("informer of CronJob is not synced")
+	}
+
+	if _, err := listers["CronJob"].ByNamespace("default").Get("backup"); err != nil {
+		t.Errorf("CronJob in batch/v1beta1 is not found. %v", err)
+	}
+	if _, ok := factory.WaitForCacheSync(ctx.Done())[schema.GroupVersionResource{Group: "batch", Version: "v1beta1", Resource: "cronjobs"}]; !ok {
+		t.Errorf("CronJob is not watched in batch/v1beta1")
+	}
+}
@@ -22,12 +22,12 @@ package pipe
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
-	v1 "k8s.io/api/core/v1"
-
 	"github.com/apache/skywalking-kubernetes-event-exporter/configs"
 	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/logger"
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/metrics"
 	exp "github.com/apache/skywalking-kubernetes-event-exporter/pkg/exporter"
 	"github.com/apache/skywalking-kubernetes-event-exporter/pkg/k8s"
 )
@@ -35,43 +35,297 @@ import (
 type workflow struct {
 	filter   *configs.FilterConfig
 	exporter exp.Exporter
-	events   chan *v1.Event
+	events   chan *k8s.Event
+	// filters filters the events of the same involved object in order, so they are exported in order.
+	filters *k8s.OrderedWorkers
 }
 
-type Pipe struct {
-	Watcher   *k8s.EventWatcher
+// generation is the set of workflows built from one version of the configurations.
+type generation struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
 	workflows []workflow
+	// redaction redacts the messages of the events before they are dispatched to the workflows.
+	redaction *configs.RedactionConfig
+
+	// deadLetters sends the events that fail to be exported to deadLetterExporter if it's configured,
+	// or deadLetterFile is appended with them.
+	deadLetters        *exp.ExporterDeadLetters
+	deadLetterExporter exp.Exporter
+	deadLettering      sync.WaitGroup
+	deadLetterFile     *exp.FileDeadLetters
+
+	once      sync.Once
+	filtering sync.WaitGroup // events that are being dispatched to the filters of the workflows
+	exporting sync.WaitGroup // exporters that are still exporting
+}
+
+type Pipe struct {
+	Clusters []*k8s.Cluster
+	// DryRun replaces all the exporters with the console exporter, which is initialized
+	// with the configurations of the replaced exporters, so that their templates are kept.
+	DryRun bool
+
+	lock    sync.RWMutex
+	current *generation
+	// stopped is set when the current generation is being stopped, it cannot be replaced any more.
+	stopped bool
+
+	// ctx is the context of the exporters, it's canceled when the grace period of the shutdown expires.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// stopping is closed when the pipe is shutting down, to stop watching the events.
+	stopping chan struct{}
+	shutdown sync.Once
+	abort    sync.Once
+	// aborted is set when the exporters are stopped at once, and dropped is the number of the dropped
+	// events by then, so that only the events still queued or in flight are reported as dropped.
+	aborted bool
+	dropped int64
 }
 
 func (p *Pipe) Init(ctx context.Context) error {
 	logger.Log.Debugf("initializing pipe")
 
-	p.workflows = []workflow{}
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	p.stopping = make(chan struct{})
+
+	g, err := newGeneration(p.ctx, &configs.GlobalConfig, p.DryRun)
+	if err != nil {
+		return err
+	}
+	p.current = g
+
+	logger.Log.Debugf("pipe has been initialized")
+
+	return nil
+}
+
+// Reload builds the workflows from the configurations and replaces the running ones atomically.
+// If the configurations are invalid, the running workflows are kept and the error is returned,
+// otherwise, the replaced workflows are stopped after all their in-flight events are exported.
+func (p *Pipe) Reload(config *configs.Config) error {
+	logger.Log.Debugf("reloading pipe")
+
+	g, err := newGeneration(p.ctx, config, p.DryRun)
+	if err != nil {
+		return err
+	}
+	g.start()
+
+	p.lock.Lock()
+	if p.stopped {
+		p.lock.Unlock()
+		g.stop()
+		return fmt.Errorf("pipe is shutting down")
+	}
+	old := p.current
+	p.current = g
+	p.lock.Unlock()
+
+	if old != nil {
+		go old.stop()
+	}
+
+	logger.Log.Debugf("pipe has been reloaded")
+
+	return nil
+}
+
+// Start watches the events of the clusters and exports them until the context is done,
+// or the pipe is shut down, in which case, the clusters are not watched any more.
+func (p *Pipe) Start(ctx context.Context) error {
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	defer stopWatching()
+	go func() {
+		select {
+		case <-p.stopping:
+			stopWatching()
+		case <-watchCtx.Done():
+		}
+	}()
+
+	events := make(chan *k8s.Event)
+
+	for _, cluster := range p.Clusters {
+		cluster.Start(watchCtx)
+
+		go func(c *k8s.Cluster) {
+			for {
+				select {
+				case <-watchCtx.Done():
+					return
+				case e := <-c.Watcher.Events:
+					select {
+					case events <- e:
+					case <-watchCtx.Done():
+						return
+					}
+				}
+			}
+		}(cluster)
+	}
+
+	return p.Run(ctx, events)
+}
+
+// Run dispatches the events to the workflows until the events channel is closed, or the pipe
+// is shut down, in which case, Run returns after all the dispatched events are exported.
+// If the context is done, or the grace period of the shutdown expires, the exporters are
+// stopped at once, and the events that are not exported yet are dropped.
+func (p *Pipe) Run(ctx context.Context, events chan *k8s.Event) error {
+	p.lock.RLock()
+	p.current.start()
+	p.lock.RUnlock()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Log.Debugf("stopping pipe")
+			p.Abort()
+			p.stop()
+			return nil
+		case <-p.stopping:
+			logger.Log.Debugf("shutting down pipe")
+			p.stop()
+			return nil
+		case e, ok := <-events:
+			if !ok {
+				logger.Log.Debugf("no more events, stopping pipe")
+				p.stop()
+				return nil
+			}
+			p.dispatch(e)
+		}
+	}
+}
+
+// Shutdown stops watching the events, and exports the dispatched events within the grace period,
+// the exporters are stopped at once when the grace period expires. It's safe to be called multiple times.
+func (p *Pipe) Shutdown(gracePeriod time.Duration) {
+	p.shutdown.Do(func() {
+		logger.Log.Infof("shutting down, exporting the in-flight events within %v", gracePeriod)
+
+		close(p.stopping)
+		time.AfterFunc(gracePeriod, p.Abort)
+	})
+}
+
+// Abort stops watching the events, and stops the exporters at once, the events that are still queued
+// or in flight are dropped. It's safe to be called multiple times, and after Shutdown.
+func (p *Pipe) Abort() {
+	p.shutdown.Do(func() {
+		close(p.stopping)
+	})
+	p.abort.Do(func() {
+		p.lock.Lock()
+		p.aborted = true
+		p.dropped = metrics.EventsDropped.Value()
+		p.lock.Unlock()
+
+		p.cancel()
+	})
+}
+
+// stop stops the current generation, and reports the dropped events if the exporters are stopped
+// before all the events are exported.
+func (p *Pipe) stop() {
+	p.lock.Lock()
+	g := p.current
+	p.stopped = true
+	p.lock.Unlock()
+
+	g.stop()
+
+	p.lock.RLock()
+	aborted, dropped := p.aborted, metrics.EventsDropped.Value()-p.dropped
+	p.lock.RUnlock()
+	if aborted {
+		logger.Log.Warnf("exporters are stopped before all the events are exported, %v events were dropped", dropped)
+	}
+	p.cancel()
+}
+
+// dispatch sends the event to the workflows of the current generation whose filter accepts the event.
+func (p *Pipe) dispatch(e *k8s.Event) {
+	p.lock.RLock()
+	g := p.current
+	g.filtering.Add(len(g.workflows))
+	p.lock.RUnlock()
+
+	e = g.redaction.RedactEvent(e)
+
+	for _, wkfl := range g.workflows {
+		if !wkfl.filters.Dispatch(g.ctx, e) {
+			metrics.DropEvents(1)
+		}
+		g.filtering.Done()
+	}
+}
+
+// filterEvent sends the event to the exporter of the workflow if the filter accepts the event.
+func (w *workflow) filterEvent(ctx context.Context, e *k8s.Event) {
+	fCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	if !w.filter.Filter(fCtx, e) {
+		select {
+		case w.events <- e:
+		case <-ctx.Done():
+			metrics.DropEvents(1)
+		}
+	}
+}
+
+func newGeneration(ctx context.Context, config *configs.Config, dryRun bool) (*generation, error) {
+	gCtx, cancel := context.WithCancel(ctx)
+
+	redaction := config.Redaction
+	if err := redaction.Init(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	g := &generation{
+		ctx:       gCtx,
+		cancel:    cancel,
+		workflows: []workflow{},
+		redaction: &redaction,
+	}
 
 	initialized := map[string]bool{}
-	for _, filter := range configs.GlobalConfig.Filters {
-		filter.Init()
+	for _, filter := range config.Filters {
+		if err := filter.Init(); err != nil {
+			cancel()
+			return nil, err
+		}
 
 		for _, name := range filter.Exporters {
-			if _, ok := configs.GlobalConfig.Exporters[name]; !ok {
-				return fmt.Errorf("exporter %v is not defined", filter.Exporters)
+			if _, ok := config.Exporters[name]; !ok {
+				cancel()
+				return nil, fmt.Errorf("exporter %v is not defined", filter.Exporters)
 			}
 			exporter := exp.GetExporter(name)
 			if exporter == nil {
-				return fmt.Errorf("exporter %v is not defined", filter.Exporters)
+				cancel()
+				return nil, fmt.Errorf("exporter %v is not defined", filter.Exporters)
+			}
+			if dryRun {
+				exporter = &exp.Console{}
 			}
 			if initialized[name] {
 				logger.Log.Debugf("exporter %+v has been initialized, skip", name)
 				continue
 			}
-			if err := exporter.Init(ctx); err != nil {
-				return err
+			if err := exporter.Init(gCtx, config.Exporters[name]); err != nil {
+				cancel()
+				return nil, err
 			}
 			initialized[name] = true
 
-			events := make(chan *v1.Event)
+			events := make(chan *k8s.Event)
 
-			p.workflows = append(p.workflows, workflow{
+			g.workflows = append(g.workflows, workflow{
 				filter:   filter,
 				exporter: exporter,
 				events:   events,
@@ -79,40 +333,121 @@ func (p *Pipe) Init(ctx context.Context) error {
 		}
 	}
 
-	if err := k8s.Registry.Init(); err != nil {
-		return err
+	if err := g.initDeadLetters(config, dryRun); err != nil {
+		cancel()
+		return nil, err
 	}
+	g.initRedaction()
 
-	logger.Log.Debugf("pipe has been initialized")
+	return g, nil
+}
+
+// initDeadLetters sets the dead letters of the exporters that send the events failing to be exported to them.
+func (g *generation) initDeadLetters(config *configs.Config, dryRun bool) error {
+	var deadLetters exp.DeadLetters
+
+	switch deadLetter := config.DeadLetter; {
+	case deadLetter.File != "":
+		file, err := exp.NewFileDeadLetters(deadLetter.File)
+		if err != nil {
+			return err
+		}
+		g.deadLetterFile = file
+		deadLetters = file
+	case deadLetter.Exporter != "":
+		exporter := exp.GetExporter(deadLetter.Exporter)
+		if exporter == nil {
+			return fmt.Errorf("exporter %v of the dead letters is not defined", deadLetter.Exporter)
+		}
+		if dryRun {
+			exporter = &exp.Console{}
+		}
+		// The dead letter exporter is a different instance from the one in the workflows,
+		// so that the events failing to be exported by it are not sent back to itself.
+		if err := exporter.Init(g.ctx, config.Exporters[deadLetter.Exporter]); err != nil {
+			return err
+		}
+		g.deadLetters = exp.NewExporterDeadLetters(g.ctx)
+		g.deadLetterExporter = exporter
+		deadLetters = g.deadLetters
+	default:
+		return nil
+	}
+
+	for _, wkfl := range g.workflows {
+		if exporter, ok := wkfl.exporter.(exp.DeadLetterExporter); ok {
+			exporter.SetDeadLetters(deadLetters)
+		}
+	}
 
 	return nil
 }
 
-func (p *Pipe) Start(ctx context.Context) error {
-	p.Watcher.Start(ctx)
+// initRedaction sets the redaction of the exporters that redact the rendered events.
+func (g *generation) initRedaction() {
+	exporters := []exp.Exporter{g.deadLetterExporter}
+	for _, wkfl := range g.workflows {
+		exporters = append(exporters, wkfl.exporter)
+	}
+	for _, exporter := range exporters {
+		if exporter, ok := exporter.(exp.RedactingExporter); ok {
+			exporter.SetRedaction(g.redaction)
+		}
+	}
+}
+
+// start starts the exporters of the generation, it's safe to be called multiple times.
+func (g *generation) start() {
+	g.once.Do(func() {
+		for i := range g.workflows {
+			w := &g.workflows[i]
+			w.filters = k8s.StartOrderedWorkers(g.ctx, k8s.DefaultWorkers, func(e *k8s.Event) {
+				w.filterEvent(g.ctx, e)
+			})
+		}
+		if g.deadLetterExporter != nil {
+			g.deadLettering.Add(1)
+			go func() {
+				defer g.deadLettering.Done()
+
+				g.deadLetterExporter.Export(g.ctx, g.deadLetters.Events())
+			}()
+		}
+		for _, wkfl := range g.workflows {
+			g.exporting.Add(1)
 
-	k8s.Registry.Start(ctx)
+			go func(w workflow) {
+				defer g.exporting.Done()
 
-	for _, wkfl := range p.workflows {
-		go wkfl.exporter.Export(ctx, wkfl.events)
+				w.exporter.Export(g.ctx, w.events)
+			}(wkfl)
+		}
+	})
+}
+
+// stop stops the generation after all its in-flight events are exported,
+// the generation must not receive new events when it's being stopped.
+func (g *generation) stop() {
+	g.filtering.Wait()
+
+	for _, wkfl := range g.workflows {
+		if wkfl.filters != nil {
+			metrics.DropEvents(wkfl.filters.Stop())
+		}
+		close(wkfl.events)
 	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			logger.Log.Debugf("stopping pipe")
-			return nil
-		case e := <-p.Watcher.Events:
-			for _, wkfl := range p.workflows {
-				go func(w workflow) {
-					fCtx, cancel := context.WithTimeout(ctx, time.Minute)
-					defer cancel()
-
-					if !w.filter.Filter(fCtx, e) {
-						w.events <- e
-					}
-				}(wkfl)
-			}
+	g.exporting.Wait()
+
+	if g.deadLetters != nil {
+		g.deadLetters.Close()
+		g.deadLettering.Wait()
+	}
+	if g.deadLetterFile != nil {
+		if err := g.deadLetterFile.Close(); err != nil {
+			logger.Log.Errorf("failed to close the dead letter file. %+v", err)
 		}
 	}
+
+	g.cancel()
 }
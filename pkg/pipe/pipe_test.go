@@ -0,0 +1,267 @@
+/*
+ * Licensed to Apache Software Foundation (ASF) under one or more contributor
+ * license agreements. See the NOTICE file distributed with
+ * this work for additional information regarding copyright
+ * ownership. Apache Software Foundation (ASF) licenses this file to you under
+ * the Apache License, Version 2.0 (the "License"); you may
+ * not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package pipe
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/apache/skywalking-kubernetes-event-exporter/configs"
+	"github.com/apache/skywalking-kubernetes-event-exporter/internal/pkg/metrics"
+	exp "github.com/apache/skywalking-kubernetes-event-exporter/pkg/exporter"
+	"github.com/apache/skywalking-kubernetes-event-exporter/pkg/k8s"
+)
+
+func init() {
+	for _, name := range []string{"first", "second"} {
+		name := name
+		exp.RegisterExporter(name, func() exp.Exporter {
+			return &recorder{name: name}
+		})
+	}
+}
+
+// recorded is the events exported by the recorders, keyed by the exporter name, and whether
+// the context of the exporters is done when they stop, which means they are not stopped gracefully.
+var recorded = struct {
+	sync.Mutex
+	events   map[string][]string
+	canceled map[string]bool
+}{}
+
+func resetRecorded() {
+	recorded.Lock()
+	defer recorded.Unlock()
+	recorded.events = map[string][]string{}
+	recorded.canceled = map[string]bool{}
+}
+
+func recordedEvents(name string) []string {
+	recorded.Lock()
+	defer recorded.Unlock()
+	return recorded.events[name]
+}
+
+func recordedCanceled(name string) (canceled, stopped bool) {
+	recorded.Lock()
+	defer recorded.Unlock()
+	canceled, stopped = recorded.canceled[name]
+	return canceled, stopped
+}
+
+// recorder records the names of the exported events, it takes `delay` to export an event.
+type recorder struct {
+	name  string
+	delay time.Duration
+}
+
+func (r *recorder) Name() string {
+	return r.name
+}
+
+func (r *recorder) Init(_ context.Context, config configs.ExporterConfig) (err error) {
+	if delay, ok := config["delay"].(string); ok {
+		r.delay, err = time.ParseDuration(delay)
+	}
+	return err
+}
+
+func (r *recorder) Export(ctx context.Context, events chan *k8s.Event) {
+	defer func() {
+		recorded.Lock()
+		defer recorded.Unlock()
+		recorded.canceled[r.name] = ctx.Err() != nil
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			select {
+			case <-time.After(r.delay):
+			case <-ctx.Done():
+				// Like the real exporters, the event being exported when they are stopped is dropped.
+				metrics.DropEvents(1)
+				return
+			}
+			recorded.Lock()
+			recorded.events[r.name] = append(recorded.events[r.name], e.Name)
+			recorded.Unlock()
+		}
+	}
+}
+
+func newEvent(name string) *k8s.Event {
+	return &k8s.Event{Event: &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: name},
+		InvolvedObject: corev1.ObjectReference{UID: types.UID(name)},
+	}}
+}
+
+func newConfig(exporter string, config configs.ExporterConfig) *configs.Config {
+	return &configs.Config{
+		Filters:   []*configs.FilterConfig{{Exporters: []string{exporter}}},
+		Exporters: map[string]configs.ExporterConfig{exporter: config},
+	}
+}
+
+// startPipe runs the pipe initialized with the config, and returns the channel to send the events,
+// and the channel that is closed when the pipe stops.
+func startPipe(t *testing.T, config *configs.Config) (p *Pipe, events chan *k8s.Event, done chan struct{}) {
+	resetRecorded()
+	configs.GlobalConfig = *config
+
+	p = &Pipe{}
+	if err := p.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	events, done = make(chan *k8s.Event), make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = p.Run(context.Background(), events)
+	}()
+	t.Cleanup(func() {
+		p.Shutdown(0)
+		<-done
+	})
+
+	return p, events, done
+}
+
+// eventually waits for the condition to be true.
+func eventually(t *testing.T, condition func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !condition(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition is not satisfied in time")
+		}
+	}
+}
+
+func TestPipe_Reload(t *testing.T) {
+	p, events, _ := startPipe(t, newConfig("first", configs.ExporterConfig{"delay": "20ms"}))
+
+	events <- newEvent("e1")
+	events <- newEvent("e2")
+	events <- newEvent("e3")
+	if err := p.Reload(newConfig("second", configs.ExporterConfig{})); err != nil {
+		t.Fatal(err)
+	}
+	events <- newEvent("e4")
+
+	eventually(t, func() bool {
+		return len(recordedEvents("second")) == 1
+	})
+	if got := recordedEvents("second"); !reflect.DeepEqual(got, []string{"e4"}) {
+		t.Errorf("events exported by the new exporter = %v, want [e4]", got)
+	}
+
+	// The replaced generation exports all its in-flight events before its exporter stops,
+	// and its context is canceled only after the exporter stops.
+	eventually(t, func() bool {
+		_, stopped := recordedCanceled("first")
+		return stopped
+	})
+	if got := recordedEvents("first"); len(got) != 3 {
+		t.Errorf("events exported by the replaced exporter = %v, want e1, e2 and e3", got)
+	}
+	if canceled, _ := recordedCanceled("first"); canceled {
+		t.Errorf("the replaced exporter is canceled rather than stopped gracefully")
+	}
+}
+
+func TestPipe_Reload_invalid(t *testing.T) {
+	p, events, _ := startPipe(t, newConfig("first", configs.ExporterConfig{}))
+
+	invalid := newConfig("second", configs.ExporterConfig{})
+	invalid.Filters[0].Exporters = []string{"undefined"}
+	if err := p.Reload(invalid); err == nil {
+		t.Fatalf("Reload() error = nil, want error")
+	}
+
+	events <- newEvent("e1")
+	eventually(t, func() bool {
+		return len(recordedEvents("first")) == 1
+	})
+	if _, stopped := recordedCanceled("first"); stopped {
+		t.Errorf("the running exporter is stopped by the invalid config")
+	}
+}
+
+func TestPipe_Shutdown(t *testing.T) {
+	tests := []struct {
+		name         string
+		delay        string
+		gracePeriod  time.Duration
+		abort        bool
+		wantExported int
+		wantDropped  int64
+	}{
+		{name: "in-flight events are exported within the grace period", delay: "20ms", gracePeriod: 5 * time.Second, wantExported: 5},
+		{name: "the rest are dropped after the grace period expires", delay: "1h", gracePeriod: 50 * time.Millisecond, wantDropped: 5},
+		{name: "the rest are dropped when aborted in the grace period", delay: "1h", gracePeriod: time.Hour, abort: true, wantDropped: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, events, done := startPipe(t, newConfig("first", configs.ExporterConfig{"delay": tt.delay}))
+
+			dropped := metrics.EventsDropped.Value()
+			for _, name := range []string{"e1", "e2", "e3", "e4", "e5"} {
+				events <- newEvent(name)
+			}
+			// The events that fail to be exported before the shutdown are not reported as dropped by it.
+			metrics.DropEvents(2)
+			p.Shutdown(tt.gracePeriod)
+			if tt.abort {
+				p.Abort()
+			}
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("the pipe is not stopped in time")
+			}
+			if got := recordedEvents("first"); len(got) != tt.wantExported {
+				t.Errorf("exported events = %v, want %v events", got, tt.wantExported)
+			}
+			if got := metrics.EventsDropped.Value() - dropped; got != tt.wantDropped+2 {
+				t.Errorf("events_dropped_total increased by %v, want %v", got, tt.wantDropped+2)
+			}
+			if p.aborted != (tt.wantDropped > 0) || (p.aborted && metrics.EventsDropped.Value()-p.dropped != tt.wantDropped) {
+				t.Errorf("aborted = %v with %v events dropped since then, want %v events", p.aborted, metrics.EventsDropped.Value()-p.dropped, tt.wantDropped)
+			}
+			if canceled, _ := recordedCanceled("first"); canceled != (tt.wantDropped > 0) {
+				t.Errorf("the exporter is canceled = %v, want %v", canceled, tt.wantDropped > 0)
+			}
+		})
+	}
+}